@@ -0,0 +1,78 @@
+// This file provides a command to re-index an explicit range of heights without
+// running the REST server or following the tip of the chain.
+//
+// ```bash
+// $ # Process heights 1000 to 2000 inclusive.
+// $ vitruvius backfill --from 1000 --to 2000
+// $
+// $ # Same thing, but ignore progress recorded by an earlier run.
+// $ vitruvius backfill --from 1000 --to 2000 --restart
+// ```
+
+package commands
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/extractor"
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+)
+
+// Command line flag variables.
+var (
+	VarBackfillFrom    int64
+	VarBackfillTo      int64
+	VarBackfillRestart bool
+)
+
+// Backfill creates the cobra struct and flags required for the `backfill`
+// command.
+func Backfill(config *types.Config) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "backfill",
+		Short: "Extract an explicit range of heights",
+		Long:  "",
+		Run:   BackfillHandler(config),
+	}
+
+	command.Flags().Int64Var(&VarBackfillFrom, "from", 0, "first height to process")
+	command.Flags().Int64Var(&VarBackfillTo, "to", 0, "last height to process")
+	command.Flags().BoolVar(&VarBackfillRestart, "restart", false, "ignore progress from a previous run")
+	command.MarkFlagRequired("from")
+	command.MarkFlagRequired("to")
+	return command
+}
+
+// BackfillHandler connects to the chain and database, then walks the requested
+// range through the extractors block iterators. Interrupting the command stops
+// it after the block in progress, a later run resumes from there.
+func BackfillHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			log.Println("Backfill: interrupted, stopping")
+			cancel()
+		}()
+
+		state, err := connect(ctx, config)
+		if err != nil {
+			log.Printf("%v", err)
+			return
+		}
+
+		if err := extractor.Backfill(ctx, config, state, VarBackfillFrom, VarBackfillTo, VarBackfillRestart); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// This file provides a command to show the configuration Hippias would run
+// with, after combining the configuration file, environment and flags. The
+// output is YAML, and can be used as a configuration file.
+//
+// ```bash
+// $ # Print the effective configuration, with a different listen address.
+// $ vitruvius config print --listen :8080
+// ```
+
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/extractor"
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+)
+
+// Config creates the cobra struct for the `config` command and its
+// subcommands.
+func Config(config *types.Config) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+		Long:  "",
+	}
+
+	command.AddCommand(&cobra.Command{
+		Use:         "print",
+		Short:       "Print the effective configuration as YAML",
+		Long:        "",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipSetup: ""},
+		Run:         ConfigPrintHandler(config),
+	})
+
+	return command
+}
+
+// ConfigPrintHandler prints the configuration, with the database password
+// redacted, then reports any problems that would stop Hippias from starting.
+func ConfigPrintHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		encoded, err := yaml.Marshal(config.Redacted())
+		if err != nil {
+			log.Fatalf("Failed to encode configuration, %v", err)
+		}
+
+		fmt.Print(string(encoded))
+
+		if err := config.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if err := extractor.CheckIterators(config.Iterators); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := extractor.CheckSchedules(config); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// This file provides commands to read back the genesis snapshots, full dumps of
+// the staking state, stored by the extractor.
+//
+// ```bash
+// $ # Write the staking state of the most recent snapshot at or below a height.
+// $ vitruvius genesis export --height 1500000 --output state.json
+// ```
+
+package commands
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/genesis"
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+)
+
+// Command line flag variables.
+var (
+	VarGenesisHeight int64
+	VarGenesisOutput string
+)
+
+// Genesis creates the cobra struct for the `genesis` command and its
+// subcommands.
+func Genesis(config *types.Config) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "genesis",
+		Short: "Read stored staking state dumps",
+		Long:  "",
+	}
+
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Write the staking state at a height as JSON",
+		Long:  "Write the staking state stored in the most recent genesis snapshot at or below a height as JSON.",
+		Args:  cobra.NoArgs,
+		Run:   GenesisExportHandler(config),
+	}
+	export.Flags().Int64Var(&VarGenesisHeight, "height", 0, "height to export the state at")
+	export.Flags().StringVar(&VarGenesisOutput, "output", "", "file to write to, standard output if empty")
+	export.MarkFlagRequired("height")
+
+	command.AddCommand(export)
+	return command
+}
+
+// GenesisExportHandler reconstructs a genesis snapshot and writes it out.
+func GenesisExportHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		state, err := connectDB(config)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		snapshot, err := genesis.Read(context.Background(), state, VarGenesisHeight)
+		if err != nil {
+			log.Fatalf("Failed to read snapshot, %v", err)
+		}
+
+		log.Printf("Exporting snapshot taken at height %d, %s", snapshot.Height, snapshot.Date)
+
+		if VarGenesisOutput == "" {
+			os.Stdout.Write(snapshot.Genesis.Serialized)
+			return
+		}
+
+		if err := ioutil.WriteFile(VarGenesisOutput, snapshot.Genesis.Serialized, 0644); err != nil {
+			log.Fatalf("Failed to write snapshot, %v", err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// This file provides commands to recover the batches the Inlet writes to disk
+// when it fails to write to the database. Files are replayed in the order they
+// were written, through the same handler the Inlet writes with, and moved to
+// an archive directory once applied so they are never applied twice.
+//
+// ```bash
+// $ # Check every batch file in the working directory, without applying any.
+// $ vitruvius inlet replay --dry-run
+// $
+// $ # Apply them, archiving each one once it has been written.
+// $ vitruvius inlet replay --archive ./inlet_applied
+// $
+// $ # Also apply files written before argument types were recorded.
+// $ vitruvius inlet replay --allow-legacy
+// ```
+
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Command line flag variables.
+var (
+	VarInletDryRun      bool
+	VarInletArchive     string
+	VarInletAllowLegacy bool
+)
+
+// inletFilePattern matches the names the Inlet gives batch files, capturing
+// the time its process started and the time the batch was written.
+var inletFilePattern = regexp.MustCompile(`^inlet_([0-9]+)_([0-9]+)\.json$`)
+
+// inletFile is a batch file waiting to be replayed.
+type inletFile struct {
+	path    string
+	created int64 // Unix time the writing process started.
+	written int64 // Unix time in nanoseconds the batch was written.
+	batch   oasis.Batch
+	legacy  bool // Written before argument types were recorded.
+}
+
+// Inlet creates the cobra struct for the `inlet` command and its subcommands.
+func Inlet(config *types.Config) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "inlet",
+		Short: "Recover batches the Inlet wrote to disk",
+		Long:  "",
+	}
+
+	replay := &cobra.Command{
+		Use:   "replay [file or directory]...",
+		Short: "Apply batch files to the database, oldest first",
+		Long: "Apply the inlet_*.json batch files found at the given paths, or in the working directory, to the " +
+			"database. Every file is checked before any is applied, and applying stops at the first failure. " +
+			"Stop any Hippias process still writing batch files before replaying them.",
+		Run: InletReplayHandler(config),
+	}
+	replay.Flags().BoolVar(&VarInletDryRun, "dry-run", false, "check the files without applying them")
+	replay.Flags().StringVar(&VarInletArchive, "archive", "inlet_applied", "directory applied files are moved to")
+	replay.Flags().BoolVar(&VarInletAllowLegacy, "allow-legacy", false, "apply files written before argument types were recorded, binary arguments in them are written as base64 text")
+
+	command.AddCommand(replay)
+	return command
+}
+
+// InletReplayHandler finds, checks, and applies batch files in order.
+func InletReplayHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		if VarInletArchive == "" {
+			log.Fatalf("An archive directory is required, applied files must not be replayed twice")
+		}
+
+		files, err := findInletFiles(args)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		if len(files) == 0 {
+			fmt.Println("No batch files to replay.")
+			return
+		}
+
+		state, err := connectDB(config)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		// Check Files
+		invalid := 0
+		for i := range files {
+			file := &files[i]
+			if err := checkInletFile(state, file); err != nil {
+				printInletStatus(file, "invalid: "+err.Error())
+				invalid++
+				continue
+			}
+
+			// Files from before argument types were recorded hold binary
+			// arguments as base64 text, which would be written to bytea and
+			// jsonb columns as is, so they are only applied when asked to.
+			status := "ok"
+			if file.legacy {
+				if !VarInletAllowLegacy {
+					printInletStatus(file, "invalid: no argument types recorded, binary arguments would be written as base64, pass --allow-legacy to apply anyway")
+					invalid++
+					continue
+				}
+				status = "ok, no argument types recorded, binary arguments are written as base64"
+			}
+			printInletStatus(file, status)
+		}
+
+		if invalid > 0 {
+			log.Fatalf("%d of %d files are invalid, nothing was applied", invalid, len(files))
+		}
+
+		if VarInletDryRun {
+			fmt.Printf("Dry run, %d files would be applied.\n", len(files))
+			return
+		}
+
+		if err := os.MkdirAll(VarInletArchive, 0755); err != nil {
+			log.Fatalf("Failed to create archive directory, %v", err)
+		}
+
+		// Apply Files
+		write := writeHandler(state)
+		for i := range files {
+			file := &files[i]
+			if err := write(file.batch); err != nil {
+				printInletStatus(file, "failed: "+err.Error())
+				log.Fatalf("Stopped after %d of %d files", i, len(files))
+			}
+
+			archived := filepath.Join(VarInletArchive, filepath.Base(file.path))
+			if err := os.Rename(file.path, archived); err != nil {
+				printInletStatus(file, "applied, but not archived: "+err.Error())
+				log.Fatalf("Stopped after %d of %d files", i+1, len(files))
+			}
+
+			printInletStatus(file, "applied, archived to "+archived)
+		}
+
+		fmt.Printf("Applied %d files.\n", len(files))
+	}
+}
+
+// findInletFiles lists the batch files at the given paths, in the order they
+// were written. Directories are searched for batch files, files must be named
+// as the Inlet names them.
+func findInletFiles(paths []string) ([]inletFile, error) {
+	var files []inletFile
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates := []string{path}
+		if info.IsDir() {
+			if candidates, err = filepath.Glob(filepath.Join(path, "inlet_*.json")); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, candidate := range candidates {
+			match := inletFilePattern.FindStringSubmatch(filepath.Base(candidate))
+			if match == nil {
+				return nil, fmt.Errorf("%s is not named like an inlet batch file", candidate)
+			}
+
+			created, _ := strconv.ParseInt(match[1], 10, 64)
+			written, _ := strconv.ParseInt(match[2], 10, 64)
+			files = append(files, inletFile{
+				path:    candidate,
+				created: created,
+				written: written,
+			})
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].written != files[j].written {
+			return files[i].written < files[j].written
+		}
+		return files[i].created < files[j].created
+	})
+
+	return files, nil
+}
+
+// checkInletFile reads a batch file, making sure every query in it is known.
+func checkInletFile(state types.State, file *inletFile) error {
+	batch, err := oasis.ReadBatchFile(file.path)
+	if err != nil {
+		return err
+	}
+
+	for i, query := range batch {
+		if _, err := state.Dot.Raw(query.Query); err != nil {
+			return fmt.Errorf("query %d: unknown query %q", i+1, query.Query)
+		}
+
+		if query.Types == nil && len(query.Args) > 0 {
+			file.legacy = true
+		}
+	}
+
+	file.batch = batch
+	return nil
+}
+
+func printInletStatus(file *inletFile, status string) {
+	fmt.Printf("%-44s %6d queries  %s\n", filepath.Base(file.path), len(file.batch), status)
+}
@@ -0,0 +1,37 @@
+// This file brings the database schema up to date before any command that uses
+// the database runs. Migrations run once the configuration is loaded, so the
+// database can come from any configuration source.
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/lib/pq"
+)
+
+func migrateDB(config *types.Config) error {
+	m, err := migrate.New(
+		"file://sql/migrations",
+		config.DatabasePath,
+	)
+
+	if err != nil {
+		return fmt.Errorf("Migration Failed: %w", err)
+	}
+
+	// Migrate all the way until tip.
+	if err := m.Up(); err != nil {
+		if err != migrate.ErrNoChange {
+			return fmt.Errorf("Migration Failed: %w", err)
+		}
+	}
+
+	return nil
+}
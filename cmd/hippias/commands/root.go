@@ -6,70 +6,149 @@
 package commands
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ChorusOne/Hippias/cmd/hippias/extractor"
+	"github.com/ChorusOne/Hippias/cmd/hippias/feed"
 	"github.com/ChorusOne/Hippias/cmd/hippias/rest"
 	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/cmd/hippias/webhook"
 	"github.com/ChorusOne/Hippias/pkg/oasis"
 )
 
-// Root creates the cobra struct required to wrap the root command.
+// skipSetup marks commands, through their annotations, that only need the
+// configuration loaded. They run even if it is invalid, and without migrating
+// the database.
+const skipSetup = "skip-setup"
+
+// Root creates the cobra struct required to wrap the root command. Every
+// command shares the configuration flags, and the configuration is loaded,
+// validated, and the database migrated before any of them run.
 func Root(config *types.Config) *cobra.Command {
-	return &cobra.Command{
-		Use:   "hippias",
-		Short: "Extractor for the Oasis blockchain.",
-		Long:  "",
-		Run:   RootHandler(config),
+	command := &cobra.Command{
+		Use:           "hippias",
+		Short:         "Extractor for the Oasis blockchain.",
+		Long:          "",
+		Run:           RootHandler(config),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			loaded, err := types.LoadConfig(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			*config = loaded
+
+			if _, skip := cmd.Annotations[skipSetup]; skip {
+				return nil
+			}
+
+			if err := config.Validate(); err != nil {
+				return err
+			}
+
+			if err := extractor.CheckIterators(config.Iterators); err != nil {
+				return err
+			}
+
+			if err := extractor.CheckSchedules(config); err != nil {
+				return err
+			}
+
+			// Process DB Migrations before entering main logic.
+			return migrateDB(config)
+		},
 	}
+
+	types.ConfigFlags(command.PersistentFlags())
+	return command
 }
 
-// RootHandler wraps the main functionality of this app, it will spawn two
-// goroutines (rest & extractor) then block forever.
+// RootHandler wraps the main functionality of this app, it will spawn three
+// goroutines (rest, extractor & webhook dispatcher) then block forever. When
+// serving state dumps there is no chain to follow, so only the REST API runs.
 func RootHandler(config *types.Config) func(*cobra.Command, []string) {
 	return func(cmd *cobra.Command, args []string) {
-		var err error
-		var api *oasis.Oasis
-		var con *sql.DB
-
-		// Initialize Oasis API, gRPC is hidden/managed by the oasis package.
-		if api, err = oasis.NewOasis(config.OasisSocket); err != nil {
-			log.Printf("Failed to initialize Oasis API, %v", err)
+		ctx := context.Background()
+		state, err := connect(ctx, config)
+		if err != nil {
+			log.Printf("%v", err)
 			return
 		}
 
-		// Connect to the Database, Postgres is the only supported DB right now.
-		if con, err = sql.Open("postgres", config.DatabasePath); err != nil {
-			log.Printf("Failed to open postgres connection, %v", err)
-			return
-		}
-
-		// Construct Shared State. The dotsql construction happens here.
-		state := types.NewState(api, con)
-
-		// Setup Inlet to manage batching queries to the database.
-		oasis.InitInlet(con, 1,
-			func(err error) {
-				log.Printf("Inlet: error occurred, %v", err)
-			},
-			func(batch oasis.Batch) error {
-				for _, q := range batch {
-					log.Printf("Execing Query: %v, %v\n", q.Query, q.Args)
-					if _, err := state.Dot.Exec(state.Db, q.Query, q.Args...); err != nil {
-						log.Fatalf("TRACK: Fuck")
-						return err
-					}
-				}
-				return nil
-			},
-		)
+		// Blocks committed by the extractor are streamed to API clients.
+		state.Feed = feed.New(config.StreamBuffer)
 
 		go rest.StartAPI(config, state)
-		go extractor.StartExtractor(config, state)
+		if len(config.StateDumps) > 0 {
+			log.Printf("Serving state dumps from %v, not following the chain", config.StateDumps)
+			select {}
+		}
+
+		go webhook.Dispatch(ctx, state)
+		go func() {
+			if err := extractor.StartExtractor(ctx, config, state); err != nil {
+				log.Printf("Extractor stopped, %v", err)
+			}
+		}()
 
 		select {}
 	}
 }
+
+// connect initializes the Oasis API and Database connections shared by every
+// command that needs to extract data, and sets up the Inlet that batches
+// queries to the database. The Oasis API stays in sync with the chain until
+// the context is cancelled, unless it is served from state dumps.
+func connect(ctx context.Context, config *types.Config) (types.State, error) {
+	var err error
+	var api oasis.API
+	var con *sql.DB
+
+	// Initialize Oasis API, gRPC is hidden/managed by the oasis package.
+	if len(config.StateDumps) > 0 {
+		if api, err = oasis.NewFile(config.StateDumps...); err != nil {
+			return types.State{}, fmt.Errorf("Failed to load state dumps, %w", err)
+		}
+	} else if api, err = oasis.NewOasis(ctx, config.OasisSocket); err != nil {
+		return types.State{}, fmt.Errorf("Failed to initialize Oasis API, %w", err)
+	}
+
+	// Connect to the Database, Postgres is the only supported DB right now.
+	if con, err = sql.Open("postgres", config.DatabasePath); err != nil {
+		return types.State{}, fmt.Errorf("Failed to open postgres connection, %w", err)
+	}
+
+	// Construct Shared State. The dotsql construction happens here.
+	state := types.NewState(api, con)
+
+	// Setup Inlet to manage batching queries to the database.
+	oasis.InitInlet(con, config.Inlet.BatchSize,
+		func(err error) {
+			log.Printf("Inlet: error occurred, %v", err)
+		},
+		writeHandler(state),
+	)
+
+	return state, nil
+}
+
+// writeHandler writes a batch of queries to the database. The Inlet writes
+// with it, and `inlet replay` reapplies batches the Inlet wrote to disk with
+// it. On failure the error is returned, so the Inlet can fall back to disk.
+func writeHandler(state types.State) func(oasis.Batch) error {
+	return func(batch oasis.Batch) error {
+		for _, q := range batch {
+			log.Printf("Execing Query: %v, %v\n", q.Query, q.Args)
+			if _, err := state.Dot.Exec(state.Db, q.Query, q.Args...); err != nil {
+				return fmt.Errorf("%s: %w", q.Query, err)
+			}
+		}
+		return nil
+	}
+}
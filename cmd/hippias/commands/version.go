@@ -18,9 +18,10 @@ import (
 // TODO: Automatically pull this from git tag/git commit hash
 func Version(_ *types.Config) *cobra.Command {
 	return &cobra.Command{
-		Use:   "version",
-		Short: "Print Version. (1.0.0)",
-		Long:  "",
+		Use:         "version",
+		Short:       "Print Version. (1.0.0)",
+		Long:        "",
+		Annotations: map[string]string{skipSetup: ""},
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Println("Version 1.0.0")
 		},
@@ -0,0 +1,286 @@
+// This file provides commands to manage webhooks, HTTP callbacks notified when
+// staking events affect a set of addresses. Deliveries are sent by the main
+// `hippias` process as it follows the chain.
+//
+// ```bash
+// $ # Notify a URL of deposits into an address, printing the generated secret.
+// $ vitruvius webhook add --url https://example.com/hook --address oasis1... --kind transfer_in
+// $
+// $ # List webhooks, and inspect the delivery log of one of them.
+// $ vitruvius webhook list
+// $ vitruvius webhook log 1
+// $
+// $ # Stop notifying a webhook.
+// $ vitruvius webhook remove 1
+// ```
+
+package commands
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/spf13/cobra"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/cmd/hippias/webhook"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Command line flag variables.
+var (
+	VarWebhookURL       string
+	VarWebhookSecret    string
+	VarWebhookAddresses []string
+	VarWebhookKinds     []string
+	VarWebhookLogLimit  int
+)
+
+// Webhook creates the cobra struct for the `webhook` command and its
+// subcommands.
+func Webhook(config *types.Config) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "webhook",
+		Short: "Manage webhooks notified of account events",
+		Long:  "",
+	}
+
+	add := &cobra.Command{
+		Use:   "add",
+		Short: "Register a webhook",
+		Long:  "Register a webhook. Kinds are any of: " + strings.Join(webhook.Kinds, ", ") + ".",
+		Args:  cobra.NoArgs,
+		Run:   WebhookAddHandler(config),
+	}
+	add.Flags().StringVar(&VarWebhookURL, "url", "", "URL deliveries are posted to")
+	add.Flags().StringVar(&VarWebhookSecret, "secret", "", "secret deliveries are signed with, generated if empty")
+	add.Flags().StringSliceVar(&VarWebhookAddresses, "address", nil, "address to watch, may be repeated")
+	add.Flags().StringSliceVar(&VarWebhookKinds, "kind", webhook.Kinds, "event kind to notify about, may be repeated")
+	add.MarkFlagRequired("url")
+	add.MarkFlagRequired("address")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List registered webhooks",
+		Long:  "",
+		Args:  cobra.NoArgs,
+		Run:   WebhookListHandler(config),
+	}
+
+	remove := &cobra.Command{
+		Use:   "remove <id>",
+		Short: "Remove a webhook and its delivery log",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   WebhookRemoveHandler(config),
+	}
+
+	deliveries := &cobra.Command{
+		Use:   "log <id>",
+		Short: "Show the most recent deliveries of a webhook",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   WebhookLogHandler(config),
+	}
+	deliveries.Flags().IntVar(&VarWebhookLogLimit, "limit", 20, "number of deliveries to show")
+
+	command.AddCommand(add, list, remove, deliveries)
+	return command
+}
+
+// connectDB opens the database for commands that do not talk to a node.
+func connectDB(config *types.Config) (types.State, error) {
+	con, err := sql.Open("postgres", config.DatabasePath)
+	if err != nil {
+		return types.State{}, fmt.Errorf("Failed to open postgres connection, %w", err)
+	}
+
+	return types.NewState(nil, con), nil
+}
+
+// WebhookAddHandler validates and stores a new webhook.
+func WebhookAddHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		for _, kind := range VarWebhookKinds {
+			if !webhook.ValidKind(kind) {
+				log.Fatalf("Unknown event kind %q, expected one of: %s", kind, strings.Join(webhook.Kinds, ", "))
+			}
+		}
+
+		for _, address := range VarWebhookAddresses {
+			var decoded oasis.Address
+			if err := decoded.UnmarshalText([]byte(address)); err != nil {
+				log.Fatalf("Invalid address %q, %v", address, err)
+			}
+		}
+
+		secret := VarWebhookSecret
+		if secret == "" {
+			random := make([]byte, 32)
+			if _, err := rand.Read(random); err != nil {
+				log.Fatalf("Failed to generate secret, %v", err)
+			}
+			secret = hex.EncodeToString(random)
+		}
+
+		state, err := connectDB(config)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer state.Db.Close()
+
+		row, err := state.Dot.QueryRow(state.Db, "insertWebhook",
+			VarWebhookURL,
+			secret,
+			pq.Array(VarWebhookAddresses),
+			pq.Array(VarWebhookKinds),
+		)
+		if err != nil {
+			log.Fatalf("Failed to add webhook, %v", err)
+		}
+
+		var id int64
+		if err := row.Scan(&id); err != nil {
+			log.Fatalf("Failed to add webhook, %v", err)
+		}
+
+		fmt.Printf("Webhook %d added.\n", id)
+		if VarWebhookSecret == "" {
+			fmt.Printf("Secret: %s\n", secret)
+		}
+	}
+}
+
+// WebhookListHandler prints every registered webhook. Secrets are not shown.
+func WebhookListHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		state, err := connectDB(config)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer state.Db.Close()
+
+		rows, err := state.Dot.Query(state.Db, "queryWebhooks")
+		if err != nil {
+			log.Fatalf("Failed to list webhooks, %v", err)
+		}
+
+		webhooks, err := webhook.ScanWebhooks(rows)
+		if err != nil {
+			log.Fatalf("Failed to list webhooks, %v", err)
+		}
+
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "ID\tURL\tADDRESSES\tKINDS\tCREATED")
+		for _, hook := range webhooks {
+			fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\n",
+				hook.ID,
+				hook.URL,
+				strings.Join(hook.Addresses, ","),
+				strings.Join(hook.Kinds, ","),
+				hook.Created.UTC().Format(time.RFC3339),
+			)
+		}
+		out.Flush()
+	}
+}
+
+// WebhookRemoveHandler deletes a webhook.
+func WebhookRemoveHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid webhook ID %q", args[0])
+		}
+
+		state, err := connectDB(config)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer state.Db.Close()
+
+		result, err := state.Dot.Exec(state.Db, "deleteWebhook", id)
+		if err != nil {
+			log.Fatalf("Failed to remove webhook, %v", err)
+		}
+
+		if removed, err := result.RowsAffected(); err == nil && removed == 0 {
+			log.Fatalf("No webhook with ID %d", id)
+		}
+
+		fmt.Printf("Webhook %d removed.\n", id)
+	}
+}
+
+// WebhookLogHandler prints the most recent deliveries of a webhook.
+func WebhookLogHandler(config *types.Config) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid webhook ID %q", args[0])
+		}
+
+		state, err := connectDB(config)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer state.Db.Close()
+
+		rows, err := state.Dot.Query(state.Db, "queryWebhookDeliveries", id, VarWebhookLogLimit)
+		if err != nil {
+			log.Fatalf("Failed to read delivery log, %v", err)
+		}
+		defer rows.Close()
+
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "ID\tHEIGHT\tEVENT\tKIND\tADDRESS\tSTATUS\tATTEMPTS\tRESPONSE\tCREATED\tDELIVERED\tERROR")
+		for rows.Next() {
+			var deliveryID, height int64
+			var eventIndex, attempts int
+			var kind, address, status string
+			var responseCode sql.NullInt64
+			var lastError sql.NullString
+			var created time.Time
+			var delivered pq.NullTime
+			if err := rows.Scan(&deliveryID, &height, &eventIndex, &kind, &address, &status, &attempts, &responseCode, &lastError, &created, &delivered); err != nil {
+				log.Fatalf("Failed to read delivery log, %v", err)
+			}
+
+			response, deliveredAt := "-", "-"
+			if responseCode.Valid {
+				response = strconv.FormatInt(responseCode.Int64, 10)
+			}
+			if delivered.Valid {
+				deliveredAt = delivered.Time.UTC().Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(out, "%d\t%d\t%d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+				deliveryID,
+				height,
+				eventIndex,
+				kind,
+				address,
+				status,
+				attempts,
+				response,
+				created.UTC().Format(time.RFC3339),
+				deliveredAt,
+				lastError.String,
+			)
+		}
+		out.Flush()
+
+		if err := rows.Err(); err != nil {
+			log.Fatalf("Failed to read delivery log, %v", err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// This file implements a one-shot extractor that processes an explicit range of
+// heights, rather than following the tip of the chain. It's used to re-index
+// history after onboarding a new network or fixing a decoder.
+
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Backfill runs every block iterator over the heights from..to inclusive. The
+// last processed height is committed along with every block, and unless
+// restart is set a previous interrupted run over an overlapping range resumes
+// from there.
+func Backfill(ctx context.Context, config *types.Config, state types.State, from, to oasis.Height, restart bool) error {
+	if from <= 0 || to < from {
+		return fmt.Errorf("Backfill: invalid range %d..%d", from, to)
+	}
+
+	// Resume from the last backfilled height if it falls within the range we
+	// were asked to process.
+	start := from
+	if !restart {
+		var lastHeight oasis.Height
+		if row, err := state.Dot.QueryRow(state.Db, "queryBackfillHeight"); err == nil {
+			if row.Scan(&lastHeight) == nil && lastHeight >= from && lastHeight < to {
+				start = lastHeight + 1
+				log.Printf("Backfill: resuming from %d\n", start)
+			}
+		}
+	}
+
+	// Setup Block Iterators
+	iterators, err := newIterators(config, state, true)
+	if err != nil {
+		return fmt.Errorf("Backfill: %w", err)
+	}
+
+	log.Printf("Backfill: processing %d..%d\n", start, to)
+
+	// Queue the whole range, the prefetch pipeline bounds how far ahead of the
+	// iterators it actually fetches.
+	heights := make(chan oasis.Height, config.PrefetchWorkers)
+	go func() {
+		defer close(heights)
+		for height := start; height <= to; height++ {
+			select {
+			case heights <- height:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	total := to - start + 1
+	began := time.Now()
+	// Heights the node has pruned will never become available, so rather
+	// than retrying them forever the backfill stops and reports the height.
+	snapshots, fetchErr := prefetch(ctx, state, config.PrefetchWorkers, heights, unavailable)
+	for snapshot := range snapshots {
+		height := snapshot.Block.Height
+		if err := commitSnapshot(ctx, state, iterators, snapshot, "updateBackfillHeight"); err != nil {
+			waitIterators(iterators)
+			return fmt.Errorf("Backfill: height %d failed, %w", height, err)
+		}
+
+		// Print Progress
+		done := height - start + 1
+		if done%100 == 0 || height == to {
+			elapsed := time.Since(began)
+			log.Printf("Backfill: %d/%d blocks (%.1f%%), %.1f blocks/s, height %d\n",
+				done,
+				total,
+				100*float64(done)/float64(total),
+				float64(done)/elapsed.Seconds(),
+				height,
+			)
+		}
+	}
+
+	// Snapshots are taken in the background once their block commits, make
+	// sure the ones queued by the range have been taken before we report
+	// success.
+	waitIterators(iterators)
+
+	if err := fetchErr(); err != nil {
+		return fmt.Errorf("Backfill: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("Backfill: interrupted, %w", err)
+	}
+
+	log.Printf("Backfill finished, took: %s", time.Since(began))
+	return nil
+}
@@ -4,6 +4,12 @@
 package extractor
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
 	"github.com/ChorusOne/Hippias/pkg/oasis"
 )
 
@@ -16,7 +22,106 @@ type StateSnapshot struct {
 }
 
 // BlockIterator defines the interface for a type to implement
-// to become a block iterator.
+// to become a block iterator. Iterators write through the transaction they are
+// given, every write for a block is committed together with the sync height,
+// and returning an error discards all of them. Work too slow for the block's
+// transaction, such as snapshots, is queued as a job in it instead and done
+// once the block has committed, see jobs.go.
 type BlockIterator interface {
-	Process(StateSnapshot)
+	Process(context.Context, *types.Tx, StateSnapshot) error
+}
+
+// Registry
+// -----------------------------------------------------------------------------
+
+// iteratorEntry describes how to construct a block iterator enabled by name in
+// the configuration.
+type iteratorEntry struct {
+	live   bool // Only run while following the tip, never when backfilling.
+	create func(config *types.Config, state types.State, backfill bool) (BlockIterator, error)
+}
+
+// registry holds every block iterator that can be enabled.
+var registry = map[string]iteratorEntry{
+	"snapshots": {
+		create: func(config *types.Config, state types.State, backfill bool) (BlockIterator, error) {
+			scheduler, err := NewScheduler(config.Snapshots.ScheduleConfig)
+			if err != nil {
+				return nil, err
+			}
+			return NewSnapshotIterator(config, state, scheduler, !backfill), nil
+		},
+	},
+	"genesis": {
+		create: func(config *types.Config, state types.State, backfill bool) (BlockIterator, error) {
+			scheduler, err := NewScheduler(config.Genesis.ScheduleConfig)
+			if err != nil {
+				return nil, err
+			}
+			return NewGenesisIterator(config, state, scheduler), nil
+		},
+	},
+	"webhooks": {
+		live: true,
+		create: func(config *types.Config, state types.State, backfill bool) (BlockIterator, error) {
+			return NewWebhookIterator(), nil
+		},
+	},
+}
+
+// namedIterator is a block iterator together with the name it was enabled by,
+// which labels its metrics.
+type namedIterator struct {
+	name string
+	BlockIterator
+}
+
+// CheckIterators verifies every name refers to a known block iterator.
+func CheckIterators(names []string) error {
+	for _, name := range names {
+		if _, ok := registry[name]; !ok {
+			known := make([]string, 0, len(registry))
+			for name := range registry {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown iterator %q, expected any of: %s", name, strings.Join(known, ", "))
+		}
+	}
+
+	return nil
+}
+
+// newIterators constructs the block iterators enabled in the configuration, in
+// the order they are listed. Iterators that only make sense for live blocks are
+// left out when backfilling.
+func newIterators(config *types.Config, state types.State, backfill bool) ([]namedIterator, error) {
+	if err := CheckIterators(config.Iterators); err != nil {
+		return nil, err
+	}
+
+	var iterators []namedIterator
+	for _, name := range config.Iterators {
+		entry := registry[name]
+		if backfill && entry.live {
+			continue
+		}
+		iterator, err := entry.create(config, state, backfill)
+		if err != nil {
+			return nil, fmt.Errorf("iterator %s: %w", name, err)
+		}
+		iterators = append(iterators, namedIterator{name, iterator})
+	}
+
+	return iterators, nil
+}
+
+// waitIterators blocks until any background work started by the iterators,
+// such as queued snapshots, has finished.
+func waitIterators(iterators []namedIterator) {
+	for _, iterator := range iterators {
+		if waiter, ok := iterator.BlockIterator.(interface{ Wait() }); ok {
+			waiter.Wait()
+		}
+	}
 }
@@ -0,0 +1,100 @@
+// This block iterator stores full dumps of the staking state, on the blocks
+// chosen by the configured genesis schedule. Together these give historical
+// state without needing an archive node.
+
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/genesis"
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+var (
+	_ BlockIterator = &GenesisIterator{}
+)
+
+// GenesisIterator takes genesis snapshots according to a Scheduler.
+type GenesisIterator struct {
+	previous  *oasis.Block
+	jobs      *jobRunner
+	scheduler Scheduler
+	state     types.State
+}
+
+func NewGenesisIterator(config *types.Config, state types.State, scheduler Scheduler) *GenesisIterator {
+	writer := genesis.NewWriter(config.Genesis.Rebase)
+	return &GenesisIterator{
+		scheduler: scheduler,
+		state:     state,
+		jobs: newJobRunner(jobGenesis, state, func(ctx context.Context, tx *types.Tx, block oasis.Block) error {
+			return snapshotFullState(ctx, state, tx, writer, block)
+		}),
+	}
+}
+
+// Process queues a genesis snapshot in the block's transaction when one is due.
+// Dumps are large and slow to take, so they are taken afterwards, see jobs.go.
+func (self *GenesisIterator) Process(ctx context.Context, tx *types.Tx, snapshot StateSnapshot) error {
+	self.jobs.Start(ctx)
+
+	// A block is processed again when its transaction failed to commit, the
+	// schedule is then compared against the block before it once more.
+	if self.previous != nil && self.previous.Height >= snapshot.Block.Height {
+		self.previous = nil
+	}
+
+	if self.previous == nil {
+		previous := previousBlock(ctx, self.state, snapshot.Block.Height)
+		self.previous = &previous
+	}
+
+	due, err := self.scheduler.Due(ctx, self.state.Api, *self.previous, snapshot.Block)
+	if err != nil {
+		return fmt.Errorf("Genesis schedule failed at %d, %w", snapshot.Block.Height, err)
+	}
+
+	if due {
+		if err := self.jobs.queue(tx, snapshot.Block); err != nil {
+			return err
+		}
+	}
+
+	self.previous = &snapshot.Block
+	return nil
+}
+
+// Wait blocks until every queued snapshot that is due has been taken.
+func (self *GenesisIterator) Wait() {
+	self.jobs.Wait()
+}
+
+// snapshotFullState persists the entire staking state of the network at a
+// block, as a diff against an earlier snapshot where possible.
+func snapshotFullState(ctx context.Context, state types.State, tx *types.Tx, writer *genesis.Writer, block oasis.Block) error {
+	log.Printf("Full Snapshot entire Oasis State at height %d", block.Height)
+	now := time.Now()
+
+	frozenAPI, err := state.Api.AtHeight(ctx, block.Height)
+	if err != nil {
+		return fmt.Errorf("Full Snapshot Failed at %d, %w", block.Height, err)
+	}
+
+	dump, err := frozenAPI.GetGenesisState(ctx)
+	if err != nil {
+		return fmt.Errorf("Full Snapshot Failed at %d, %w", block.Height, err)
+	}
+
+	if err := writer.Write(tx, block, dump); err != nil {
+		return fmt.Errorf("Full Snapshot Failed at %d, %w", block.Height, err)
+	}
+
+	elapsed := time.Since(now)
+	log.Printf("Full Snapshot finished, took: %s", elapsed)
+	return nil
+}
@@ -1,12 +1,17 @@
-// This is the default block iterator, it will attempt to take snapshots once a
-// day on the first block of the day.
+// This is the default block iterator, it persists the transactions, events and
+// commissions of every block, and takes account snapshots on the blocks chosen
+// by the configured snapshot schedule. Snapshots missed while the extractor was
+// not running are caught up when it resumes. See jobs.go for how snapshots are
+// taken once scheduled.
 
 package extractor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/ChorusOne/Hippias/cmd/hippias/types"
@@ -17,153 +22,292 @@ var (
 	_ BlockIterator = &SnapshotIterator{}
 )
 
-// SnapshotIterator is an iterator that attempts to take daily snapshots of
+// SnapshotIterator is an iterator that persists block data and takes account
+// snapshots according to a Scheduler.
 type SnapshotIterator struct {
-	lastObserved time.Time
-	config       *types.Config
-	state        types.State
+	catchUp   bool
+	previous  *oasis.Block
+	config    *types.Config
+	jobs      *jobRunner
+	pending   sync.WaitGroup
+	scheduler Scheduler
+	state     types.State
 }
 
-func NewSnapshotIterator(config *types.Config, state types.State) *SnapshotIterator {
+// NewSnapshotIterator creates a SnapshotIterator, when catchUp is set missed
+// snapshots are queued in the background before the first block is processed.
+func NewSnapshotIterator(config *types.Config, state types.State, scheduler Scheduler, catchUp bool) *SnapshotIterator {
 	return &SnapshotIterator{
-		config: config,
-		state:  state,
+		catchUp:   catchUp,
+		config:    config,
+		scheduler: scheduler,
+		state:     state,
+		jobs: newJobRunner(jobAccounts, state, func(ctx context.Context, tx *types.Tx, block oasis.Block) error {
+			return snapshotState(ctx, config, state, tx, block)
+		}),
 	}
 }
 
-func (self *SnapshotIterator) Process(snapshot StateSnapshot) {
-	snapshotTransactions(self.config, self.state, snapshot.Block, snapshot.Transactions)
-	snapshotEvents(self.config, self.state, snapshot.Block, snapshot.Events)
-	snapshotCommission(self.config, self.state, snapshot.Block)
-	if isDailyBlock(self.lastObserved, snapshot.Block.Time) {
-		go snapshotState(self.config, self.state, snapshot.Block)
+// Process writes the transactions, events and commissions of a block using the
+// provided transaction. Account snapshots are slow, so when one is due only a
+// job is queued in the transaction, and the snapshot is taken afterwards.
+func (self *SnapshotIterator) Process(ctx context.Context, tx *types.Tx, snapshot StateSnapshot) error {
+	self.jobs.Start(ctx)
+
+	if err := snapshotTransactions(self.config, tx, snapshot.Block, snapshot.Transactions); err != nil {
+		return err
+	}
+
+	if err := snapshotEvents(self.config, tx, snapshot.Block, snapshot.Events); err != nil {
+		return err
+	}
+
+	if err := snapshotCommission(ctx, self.config, self.state, tx, snapshot.Block); err != nil {
+		return err
+	}
+
+	// A block is processed again when its transaction failed to commit, the
+	// schedule is then compared against the block before it once more.
+	if self.previous != nil && self.previous.Height >= snapshot.Block.Height {
+		self.previous = nil
+	}
+
+	if self.previous == nil {
+		previous := previousBlock(ctx, self.state, snapshot.Block.Height)
+		self.previous = &previous
+
+		if self.catchUp {
+			self.catchUp = false
+			self.pending.Add(1)
+			go func() {
+				defer self.pending.Done()
+				catchUpSnapshots(ctx, self.config, self.state, snapshot.Block)
+			}()
+		}
+	}
+
+	due, err := self.scheduler.Due(ctx, self.state.Api, *self.previous, snapshot.Block)
+	if err != nil {
+		return fmt.Errorf("Snapshot schedule failed at %d, %w", snapshot.Block.Height, err)
+	}
+
+	if due {
+		if err := self.jobs.queue(tx, snapshot.Block); err != nil {
+			return err
+		}
 	}
 
-	self.lastObserved = snapshot.Block.Time
+	self.previous = &snapshot.Block
+	return nil
+}
+
+// Wait blocks until the catch-up has finished and every queued snapshot that
+// is due has been taken.
+func (self *SnapshotIterator) Wait() {
+	self.pending.Wait()
+	self.jobs.Wait()
 }
 
 // Internal Extractor Functions
 // -----------------------------------------------------------------------------
 
-// isDailyBlock checks that the block being observed, is the first block of the
-// day. To do this check, the last observed block time must be passed in.
-func isDailyBlock(previous time.Time, next time.Time) bool {
-	midnight := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
-	return previous.Before(midnight) && (next.After(midnight) || next.Equal(midnight))
+// previousBlock fetches the block before a height, so that the schedule can be
+// followed from the first block processed after a restart. The zero Block is
+// returned when there is none, or it cannot be fetched.
+func previousBlock(ctx context.Context, state types.State, height oasis.Height) oasis.Block {
+	if height <= 1 {
+		return oasis.Block{}
+	}
+
+	block, err := state.Api.BlockAt(ctx, height-1)
+	if err != nil {
+		log.Printf("Failed to Retrieve Block %d, %v", height-1, err)
+		return oasis.Block{}
+	}
+
+	return block
 }
 
 // snapshotCommission collects Commission information for validators over time.
-func snapshotCommission(config *types.Config, state types.State, block oasis.Block) {
-	frozenAPI := state.Api.AtHeight(block.Height)
-	addresses := frozenAPI.Accounts()
+func snapshotCommission(ctx context.Context, config *types.Config, state types.State, tx *types.Tx, block oasis.Block) error {
+	frozenAPI, err := state.Api.AtHeight(ctx, block.Height)
+	if err != nil {
+		return err
+	}
+
+	addresses, err := frozenAPI.Accounts(ctx)
+	if err != nil {
+		return err
+	}
 
 	for _, address := range addresses {
-		commission, base, err := frozenAPI.GetValidatorCommission(address)
+		commission, base, err := frozenAPI.GetValidatorCommission(ctx, address)
+		if err != nil {
+			return err
+		}
+
 		if commission != nil && !commission.IsZero() {
-			log.Printf("Commission: %v: %v, %v, %v", address, commission, base, err)
+			log.Printf("Commission: %v: %v, %v", address, commission, base)
 			var hundred oasis.Amount
 			hundred.FromInt64(100)
+			commission = commission.Clone()
 			commission.Mul(&hundred)
 			commission.Quo(base)
-			if _, err = state.Dot.Exec(state.Db, "insertValidatorCommission",
+			if _, err = tx.Exec("insertValidatorCommission",
 				commission.String(),
 				address.String(),
 				block.Height,
 			); err != nil {
-				log.Printf("Commission Insert for %v Failed, %v", address, err)
+				return fmt.Errorf("Commission Insert for %v Failed, %w", address, err)
 			}
 		}
 	}
+
+	return nil
 }
 
-// snapshotState persists the entire current state of all accounts with nonzero
-// balance on the oasis network. This is quite slow so this is done only once a
-// day, for the first block of the day.
-func snapshotState(config *types.Config, state types.State, block oasis.Block) {
+// snapshotState persists the entire state of all accounts on the oasis
+// network at a block. This is quite slow so this is done only on the blocks
+// chosen by the snapshot schedule. Accounts that cannot be read are skipped,
+// but a failed write aborts the whole snapshot.
+func snapshotState(ctx context.Context, config *types.Config, state types.State, tx *types.Tx, block oasis.Block) error {
 	log.Printf("Snapshot Triggered at %s", block.Time)
-	frozenAPI := state.Api.AtHeight(block.Height)
 	now := time.Now()
 
-	// Extract all accounts from the state.
-	addresses := frozenAPI.Accounts()
-	for i, address := range addresses {
-		mappedAccount, err := frozenAPI.Account(address)
+	frozenAPI, err := state.Api.AtHeight(ctx, block.Height)
+	if err != nil {
+		return fmt.Errorf("Snapshot Failed at %s, %w", block.Time, err)
+	}
 
-		// Extract all delegations from the state.
-		// allDelegations := frozenAPI.Delegations()
+	snapshots, err := readAccountSnapshots(ctx, frozenAPI, func(address oasis.Address) (int64, error) {
+		row, err := tx.QueryRow("queryGetLastRewardBalance",
+			address.String(),
+			block.Height,
+		)
 		if err != nil {
-			log.Printf("Failed to Retrieve Account: %s, %v", address, err)
-			continue
+			return 0, err
 		}
 
-		accountDelegations := frozenAPI.AccountDelegations(address)
-		encodedDelegations, err := json.Marshal(&accountDelegations)
-		if err != nil {
-			log.Printf("Failed to Encode Delegations: %s", address)
-			log.Printf("%v", err)
-			continue
-		}
+		var tokens int64
+		err = row.Scan(&tokens)
+		return tokens, err
+	})
+	if err != nil {
+		return fmt.Errorf("Snapshot Failed at %s, %w", block.Time, err)
+	}
 
-		stakedJson, err := json.Marshal(&mappedAccount.StakedBalance)
-		if err != nil {
-			log.Printf("Failed to Encode Balance: %s", address)
-			log.Printf("%v", err)
-			continue
-		}
+	// Progress is logged every 5%, or every account for small ledgers.
+	step := len(snapshots) / 20
+	if step < 1 {
+		step = 1
+	}
 
-		debondingJson, err := json.Marshal(&mappedAccount.DebondingBalance)
+	for i, snapshot := range snapshots {
+		encodedDelegations, err := json.Marshal(&snapshot.delegations)
 		if err != nil {
-			log.Printf("Failed to Encode Balance: %s", address)
+			log.Printf("Failed to Encode Delegations: %s", snapshot.address)
 			log.Printf("%v", err)
 			continue
 		}
 
-		// Get Last Reward Balance
-		row, err := state.Dot.QueryRow(state.Db, "queryGetLastRewardBalance",
-			address.String(),
-			block.Height,
-		)
+		stakedJson, err := json.Marshal(&snapshot.account.StakedBalance)
 		if err != nil {
-			log.Printf("Failed to Fetch Reward Balance: %s", address)
+			log.Printf("Failed to Encode Balance: %s", snapshot.address)
 			log.Printf("%v", err)
 			continue
 		}
 
-		var tokens int
-		err = row.Scan(&tokens)
+		debondingJson, err := json.Marshal(&snapshot.account.DebondingBalance)
 		if err != nil {
-			log.Printf("User has no Reward Entries, %s: %v", address, err)
+			log.Printf("Failed to Encode Balance: %s", snapshot.address)
+			log.Printf("%v", err)
 			continue
 		}
 
 		// Snapshot Account Itself
-		oasis.PushQuery("insertSnapshot",
-			address.String(),
-			mappedAccount.Balance,
+		if _, err := tx.Exec("insertSnapshot",
+			snapshot.address.String(),
+			snapshot.account.Balance,
 			stakedJson,
 			debondingJson,
-			tokens,
+			snapshot.rewards,
 			encodedDelegations,
 			false,
 			false,
 			block.Height,
 			block.Time,
-		)
+		); err != nil {
+			return fmt.Errorf("Failed Snapshot Insert: %s, %w", snapshot.address, err)
+		}
 
 		log.Println("Wrote ok!")
 
 		// Print Progress
-		if i%(len(addresses)/20) == 0 {
-			log.Printf("Snapshot %d%% complete", 5*(i/(len(addresses)/20)))
+		if i%step == 0 {
+			log.Printf("Snapshot %d%% complete", 100*i/len(snapshots))
 		}
 	}
 
 	elapsed := time.Since(now)
 	log.Printf("Snapshot finished, took: %s", elapsed)
+	return nil
+}
+
+// accountSnapshot is a single account as written to account_snapshots.
+type accountSnapshot struct {
+	address     oasis.Address
+	account     *oasis.Account
+	delegations []oasis.Delegation
+	rewards     int64
+}
+
+// readAccountSnapshots reads every account in the ledger of an API frozen at a
+// height, along with the rewards each has received up to it. Accounts that
+// cannot be read from the node are skipped, but a failed reward lookup fails
+// the whole read. Every account is returned, including those that have never
+// received a reward, so snapshot readers can rely on all accounts being
+// present at every snapshot height.
+func readAccountSnapshots(ctx context.Context, api oasis.API, rewards func(oasis.Address) (int64, error)) ([]accountSnapshot, error) {
+	addresses, err := api.Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]accountSnapshot, 0, len(addresses))
+	for _, address := range addresses {
+		account, err := api.Account(ctx, address)
+		if err != nil {
+			log.Printf("Failed to Retrieve Account: %s, %v", address, err)
+			continue
+		}
+
+		delegations, err := api.AccountDelegations(ctx, address)
+		if err != nil {
+			log.Printf("Failed to Retrieve Delegations: %s, %v", address, err)
+			continue
+		}
+
+		tokens, err := rewards(address)
+		if err != nil {
+			return nil, fmt.Errorf("readAccountSnapshots: failed to fetch rewards of %s, %w", address, err)
+		}
+
+		snapshots = append(snapshots, accountSnapshot{
+			address:     address,
+			account:     account,
+			delegations: delegations,
+			rewards:     tokens,
+		})
+	}
+
+	return snapshots, nil
 }
 
-func snapshotTransactions(config *types.Config, state types.State, block oasis.Block, txs []oasis.Transaction) {
-	for _, tx := range txs {
+// snapshotTransactions persists every transaction included in a block. Any
+// transaction that cannot be encoded is skipped, but a failed write aborts the
+// whole block.
+func snapshotTransactions(config *types.Config, dbTx *types.Tx, block oasis.Block, txs []oasis.Transaction) error {
+	for i, tx := range txs {
 		var encodedTx []byte
 		var err error
 		if encodedTx, err = json.Marshal(&tx.Payload); err != nil {
@@ -171,7 +315,25 @@ func snapshotTransactions(config *types.Config, state types.State, block oasis.B
 			continue
 		}
 
-		if _, err = state.Dot.Exec(state.Db, "insertTransaction",
+		var encodedEvents []byte
+		if encodedEvents, err = json.Marshal(&tx.Events); err != nil {
+			log.Printf("Failed to Encode Tx Events: %v, %v", tx.Method, err)
+			continue
+		}
+
+		// Result columns stay NULL when the node could not tell us the
+		// outcome, or when there was no error to record.
+		var errModule, errCode, errMessage, gasUsed interface{}
+		if tx.Error != nil {
+			errModule = tx.Error.Module
+			errCode = tx.Error.Code
+			errMessage = tx.Error.Message
+		}
+		if tx.Status != oasis.TxStatusUnknown {
+			gasUsed = tx.GasUsed
+		}
+
+		if _, err = dbTx.Exec("insertTransaction",
 			tx.Method,
 			encodedTx,
 			block.Height,
@@ -181,94 +343,110 @@ func snapshotTransactions(config *types.Config, state types.State, block oasis.B
 			tx.Gas,
 			tx.GasPrice.String(),
 			tx.Hash,
+			i,
+			tx.Status,
+			errModule,
+			errCode,
+			errMessage,
+			gasUsed,
+			encodedEvents,
 		); err != nil {
-			log.Printf("Failed to Persist Tx: %v, %v", tx.Method, err)
-			continue
+			return fmt.Errorf("Failed to Persist Tx: %v, %w", tx.Method, err)
 		}
 
 		log.Printf("Persisted Tx: %v", tx.Method)
 	}
-}
 
-func snapshotFullState(config *types.Config, state types.State, block oasis.Block) {
-	log.Printf("Full Snapshot entire Oasis State at height %d", block.Height)
-	now := time.Now()
-	elapsed := time.Since(now)
-	log.Printf("Full Snapshot finished, took: %s", elapsed)
+	return nil
 }
 
 // snapshotEvents persists each individual event that occurs on the network.
-func snapshotEvents(config *types.Config, state types.State, block oasis.Block, events []oasis.StakingEvent) {
-	for _, event := range events {
+// Events are identified by their index in the blocks list of events, so the
+// index of every event, including unknown ones, must stay stable. Any events
+// already recorded at the height are removed first, as rows from before events
+// were numbered by the node may carry a different index for the same event.
+func snapshotEvents(config *types.Config, tx *types.Tx, block oasis.Block, events []oasis.StakingEvent) error {
+	if _, err := tx.Exec("deleteBlockEvents", block.Height); err != nil {
+		return fmt.Errorf("Failed Event Delete: %w", err)
+	}
+
+	for i, event := range events {
 		log.Printf("Event Observed: %v", event)
 
 		switch {
 		// Transfer events occur when balance is moved from one address balance to
 		// another.
 		case event.Transfer != nil:
-			if _, err := state.Dot.Exec(state.Db, "insertTransfer",
+			if _, err := tx.Exec("insertTransfer",
 				event.Transfer.From.String(),
 				event.Transfer.To.String(),
 				event.Transfer.Tokens.String(),
 				event.Transfer.Hash,
 				block.Height,
 				block.Time,
+				i,
 			); err != nil {
-				fmt.Printf("Failed Transfer Insert: %v\n", err)
+				return fmt.Errorf("Failed Transfer Insert: %w", err)
 			}
 
 		// Burn events occur when someone is slashed, this tells us who was slashed
 		// and by how much.
 		case event.Burn != nil:
-			if _, err := state.Dot.Exec(state.Db, "insertBurn",
+			if _, err := tx.Exec("insertBurn",
 				event.Burn.Owner.String(),
 				event.Burn.Tokens.String(),
 				event.Burn.Hash,
 				block.Height,
 				block.Time,
+				i,
 			); err != nil {
-				fmt.Printf("Failed Burn Insert: %v\n", err)
+				return fmt.Errorf("Failed Burn Insert: %w", err)
 			}
 
 		// Escrow occurs whenever a delegation is modified.
 		case event.Escrow != nil:
 			switch {
 			case event.Escrow.Add != nil:
-				if _, err := state.Dot.Exec(state.Db, "insertEscrowEvent", "add",
+				if _, err := tx.Exec("insertEscrowEvent", "add",
 					event.Escrow.Add.Owner.String(),
 					event.Escrow.Add.Escrow.String(),
 					event.Escrow.Add.Tokens.String(),
 					event.Escrow.Add.Hash,
 					block.Height,
 					block.Time,
+					i,
 				); err != nil {
-					fmt.Printf("Failed Escrow Add Insert: %v\n", err)
+					return fmt.Errorf("Failed Escrow Add Insert: %w", err)
 				}
 
 			case event.Escrow.Take != nil:
-				if _, err := state.Dot.Exec(state.Db, "insertEscrowEvent", "take",
+				if _, err := tx.Exec("insertEscrowEvent", "take",
 					event.Escrow.Take.Owner.String(),
 					"",
 					event.Escrow.Take.Tokens.String(),
 					event.Escrow.Take.Hash,
 					block.Height,
 					block.Time,
+					i,
 				); err != nil {
-					fmt.Printf("Failed Escrow Take Insert: %v\n", err)
+					return fmt.Errorf("Failed Escrow Take Insert: %w", err)
 				}
 
 			case event.Escrow.Reclaim != nil:
-				if _, err := state.Dot.Exec(state.Db, "insertEscrowEvent", "add",
+				if _, err := tx.Exec("insertEscrowEvent", "reclaim",
 					event.Escrow.Reclaim.Owner.String(),
 					event.Escrow.Reclaim.Escrow.String(),
 					event.Escrow.Reclaim.Tokens.String(),
 					event.Escrow.Reclaim.Hash,
 					block.Height,
 					block.Time,
+					i,
 				); err != nil {
-					fmt.Printf("Failed Escrow Reclaim Insert: %v\n", err)
+					return fmt.Errorf("Failed Escrow Reclaim Insert: %w", err)
 				}
 			}
 		}
 	}
+
+	return nil
 }
@@ -0,0 +1,68 @@
+package extractor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// testAddress derives a distinct address from a single byte.
+func testAddress(b byte) oasis.Address {
+	var key signature.PublicKey
+	key[0] = b
+	return staking.NewAddress(key)
+}
+
+func TestReadAccountSnapshotsIncludesUnrewarded(t *testing.T) {
+	holder, delegator, validator := testAddress(1), testAddress(2), testAddress(3)
+
+	ledger := map[staking.Address]*staking.Account{}
+	for _, address := range []oasis.Address{holder, delegator, validator} {
+		var account staking.Account
+		account.General.Balance = *quantity.NewFromUint64(100)
+		ledger[address] = &account
+	}
+
+	fake := oasis.NewFake(oasis.FakeBlock{
+		Block: oasis.Block{Height: 10},
+		State: staking.Genesis{
+			Ledger: ledger,
+			Delegations: map[staking.Address]map[staking.Address]*staking.Delegation{
+				delegator: {validator: {Shares: *quantity.NewFromUint64(5)}},
+			},
+		},
+	})
+
+	// Only the validator has ever been rewarded, the others have no reward rows
+	// and so are given zero, as queryGetLastRewardBalance does.
+	rewards := map[oasis.Address]int64{validator: 42}
+	snapshots, err := readAccountSnapshots(context.Background(), fake, func(address oasis.Address) (int64, error) {
+		return rewards[address], nil
+	})
+	if err != nil {
+		t.Fatalf("readAccountSnapshots: %v", err)
+	}
+
+	if len(snapshots) != len(ledger) {
+		t.Fatalf("readAccountSnapshots: got %d snapshots for %d accounts", len(snapshots), len(ledger))
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.account == nil || snapshot.account.Balance != "100" {
+			t.Fatalf("readAccountSnapshots: unexpected account %+v", snapshot.account)
+		}
+
+		if snapshot.rewards != rewards[snapshot.address] {
+			t.Fatalf("readAccountSnapshots: %s has rewards %d, expected %d", snapshot.address, snapshot.rewards, rewards[snapshot.address])
+		}
+
+		if snapshot.address == delegator && len(snapshot.delegations) != 1 {
+			t.Fatalf("readAccountSnapshots: unexpected delegations %+v", snapshot.delegations)
+		}
+	}
+}
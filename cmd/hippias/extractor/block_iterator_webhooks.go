@@ -0,0 +1,76 @@
+// This block iterator queues webhook notifications for the staking events in
+// each block. Deliveries are written in the block's transaction, so they exist
+// exactly when the block does, and are sent afterwards by the webhook
+// dispatcher.
+
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/cmd/hippias/webhook"
+)
+
+var (
+	_ BlockIterator = &WebhookIterator{}
+)
+
+// WebhookIterator matches every event against the registered webhooks. It only
+// runs while following the tip, so history reprocessed by a backfill never
+// notifies anyone.
+type WebhookIterator struct{}
+
+func NewWebhookIterator() *WebhookIterator {
+	return &WebhookIterator{}
+}
+
+// Process queues a delivery for every webhook watching an address affected by
+// one of the block's events. Webhooks are read within the same transaction, so
+// a webhook registered mid-block is either notified of the whole block or none
+// of it.
+func (self *WebhookIterator) Process(ctx context.Context, tx *types.Tx, snapshot StateSnapshot) error {
+	if len(snapshot.Events) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query("queryWebhooks")
+	if err != nil {
+		return fmt.Errorf("WebhookIterator: failed to load webhooks, %w", err)
+	}
+
+	webhooks, err := webhook.ScanWebhooks(rows)
+	if err != nil {
+		return fmt.Errorf("WebhookIterator: failed to load webhooks, %w", err)
+	}
+
+	for i, event := range snapshot.Events {
+		for _, hook := range webhooks {
+			for _, payload := range hook.Match(event) {
+				payload.Height = snapshot.Block.Height
+				payload.Date = snapshot.Block.Time
+				payload.EventIndex = i
+
+				encoded, err := json.Marshal(&payload)
+				if err != nil {
+					return fmt.Errorf("WebhookIterator: failed to encode payload, %w", err)
+				}
+
+				if _, err := tx.Exec("insertWebhookDelivery",
+					hook.ID,
+					snapshot.Block.Height,
+					i,
+					payload.Kind,
+					payload.Address,
+					encoded,
+				); err != nil {
+					return fmt.Errorf("WebhookIterator: failed to queue delivery, %w", err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+// This file queues the account snapshots that were missed while the extractor
+// was not running. The schedule is replayed from the last snapshot stored in
+// the database, and the block each missed snapshot should have been taken at
+// is found by searching historical heights. Queued snapshots are taken like
+// any other, see jobs.go.
+
+package extractor
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// catchUpSnapshots queues every snapshot the schedule was due to take after the
+// last stored snapshot, up to and including the block before the extractor
+// resumed. Nothing is queued if no snapshot was ever stored, and at most
+// snapshots.catch_up are queued, oldest first.
+func catchUpSnapshots(ctx context.Context, config *types.Config, state types.State, resumed oasis.Block) {
+	limit := config.Snapshots.CatchUp
+	if limit == 0 || resumed.Height <= 1 {
+		return
+	}
+
+	// The live scheduler belongs to the iterator, searching uses its own.
+	scheduler, err := NewScheduler(config.Snapshots.ScheduleConfig)
+	if err != nil {
+		log.Printf("Snapshot Catch-up Failed, %v", err)
+		return
+	}
+
+	row, err := state.Dot.QueryRowContext(ctx, state.Db, "queryAccountSnapshotHeight", resumed.Height-1)
+	if err != nil {
+		log.Printf("Snapshot Catch-up Failed, %v", err)
+		return
+	}
+
+	var last sql.NullInt64
+	if err := row.Scan(&last); err != nil {
+		log.Printf("Snapshot Catch-up Failed, %v", err)
+		return
+	}
+
+	if !last.Valid {
+		log.Printf("Snapshot Catch-up: no previous snapshots, nothing to catch up")
+		return
+	}
+
+	after, err := state.Api.BlockAt(ctx, last.Int64)
+	if err != nil {
+		log.Printf("Snapshot Catch-up Failed, %v", err)
+		return
+	}
+
+	for taken := 0; ; taken++ {
+		block, found, err := nextDue(ctx, state.Api, scheduler, after, resumed.Height-1)
+		if err != nil {
+			log.Printf("Snapshot Catch-up Failed after %s, %v", after.Time, err)
+			return
+		}
+
+		if !found {
+			log.Printf("Snapshot Catch-up finished, queued %d snapshots", taken)
+			return
+		}
+
+		if taken == limit {
+			log.Printf("Snapshot Catch-up stopped after %d snapshots, snapshots from %s on were skipped", taken, block.Time)
+			return
+		}
+
+		log.Printf("Snapshot Missed at %s, catching up", block.Time)
+		if _, err := state.Dot.ExecContext(ctx, state.Db, "insertSnapshotJob", jobAccounts, block.Height, block.Time); err != nil {
+			log.Printf("Snapshot Catch-up Failed at %s, %v", block.Time, err)
+			return
+		}
+		after = block
+	}
+}
+
+// nextDue finds the first block after `after`, and at or below `until`, that
+// the schedule is due on. Once a schedule is due relative to a block it stays
+// due for every later block, so the heights can be binary searched.
+func nextDue(ctx context.Context, api oasis.API, scheduler Scheduler, after oasis.Block, until oasis.Height) (oasis.Block, bool, error) {
+	if until <= after.Height {
+		return oasis.Block{}, false, nil
+	}
+
+	last, err := api.BlockAt(ctx, until)
+	if err != nil {
+		return oasis.Block{}, false, err
+	}
+
+	due, err := scheduler.Due(ctx, api, after, last)
+	if err != nil || !due {
+		return oasis.Block{}, false, err
+	}
+
+	// Invariant: the schedule is due at `found`, and not due below `low`.
+	low, found := after.Height+1, last
+	for low < found.Height {
+		middle := low + (found.Height-low)/2
+		block, err := api.BlockAt(ctx, middle)
+		if err != nil {
+			return oasis.Block{}, false, err
+		}
+
+		due, err := scheduler.Due(ctx, api, after, block)
+		if err != nil {
+			return oasis.Block{}, false, err
+		}
+
+		if due {
+			found = block
+		} else {
+			low = middle + 1
+		}
+	}
+
+	return found, true, nil
+}
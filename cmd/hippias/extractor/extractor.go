@@ -4,9 +4,12 @@
 package extractor
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/ChorusOne/Hippias/cmd/hippias/feed"
 	"github.com/ChorusOne/Hippias/cmd/hippias/types"
 	"github.com/ChorusOne/Hippias/pkg/oasis"
 )
@@ -14,15 +17,17 @@ import (
 // Main Extractor Logic
 // -----------------------------------------------------------------------------
 
-func StartExtractor(config *types.Config, state types.State) error {
+// StartExtractor follows the tip of the chain, processing every block until the
+// context is cancelled or the block subscription ends.
+func StartExtractor(ctx context.Context, config *types.Config, state types.State) error {
 	log.Print("Extractor Starting")
 
 	var err error
-	var blocks chan oasis.Block
+	var blocks <-chan oasis.Block
 
 	// Use a Blocks iterator in order to pump the event loop that runs
 	// the extractor.
-	if blocks, err = state.Api.WatchBlocks(); err != nil {
+	if blocks, err = state.Api.WatchBlocks(ctx); err != nil {
 		return fmt.Errorf("StartExtractor: WatchBlocks failed, %w", err)
 	}
 
@@ -34,16 +39,25 @@ func StartExtractor(config *types.Config, state types.State) error {
 		row.Scan(&lastHeight)
 	}
 
+	// Blocks committed before this run were never published, so streaming
+	// clients cannot resume from them.
+	state.Feed.Start(lastHeight + 1)
+
 	// Setup Block Iterators
-	iterators := []BlockIterator{
-		NewSnapshotIterator(config, state),
+	iterators, err := newIterators(config, state, false)
+	if err != nil {
+		return fmt.Errorf("StartExtractor: %w", err)
 	}
 
 	log.Printf("Starting Sync from %d\n", lastHeight)
 
-	for {
-		select {
-		case msg := <-blocks:
+	// Observed blocks are turned into a sequence of heights to process, which
+	// the prefetch pipeline fetches ahead of the block iterators.
+	heights := make(chan oasis.Height, config.PrefetchWorkers)
+	go func() {
+		defer close(heights)
+		observed := false
+		for msg := range blocks {
 			log.Printf("Height %d Observed. Last was %d. Timestamp: %s\n", msg.Height, lastHeight, msg.Time)
 
 			// Oasis gRPC is unreliable, and skips blocks. So here we'll track
@@ -54,30 +68,89 @@ func StartExtractor(config *types.Config, state types.State) error {
 				log.Printf("Blocks Skipped: %d", blockDistance)
 			}
 
+			// The gap before the first observed block is the chain produced
+			// while we were stopped, not blocks the subscription skipped, so
+			// it is filled in without being counted.
+			if blockDistance > 0 && observed {
+				metricSkippedBlocks.Add(float64(blockDistance))
+			}
+			observed = true
+
 			// For each Block we know we've skipped (hopefully only ever 1 at a
-			// time) we run block processors.
+			// time) we queue the height for processing.
 			for ; blockDistance >= 0; blockDistance-- {
-				currentBlock := msg.Height - blockDistance
-				log.Printf("Processing Block %d\n", currentBlock)
-
-				api := state.Api.AtHeight(currentBlock)
-				block, _ := api.GetBlock()
-				events := api.GetEvents()
-				transactions := api.GetTransactions()
-
-				for _, iterator := range iterators {
-					iterator.Process(StateSnapshot{
-						Block:        block,
-						Events:       events,
-						Transactions: transactions,
-					})
+				select {
+				case heights <- msg.Height - blockDistance:
+				case <-ctx.Done():
+					return
 				}
-
-				// Update Height
-				state.Dot.Exec(state.Db, "updateLatestSyncHeight", block.Height)
 			}
 
 			lastHeight = msg.Height
 		}
+	}()
+
+	// Heights at the tip are fetched as soon as they are observed, so a node
+	// briefly reporting one as missing is retried rather than treated as
+	// permanent.
+	snapshots, _ := prefetch(ctx, state, config.PrefetchWorkers, heights, nil)
+	for snapshot := range snapshots {
+		log.Printf("Processing Block %d\n", snapshot.Block.Height)
+
+		// A block that fails to commit leaves no trace in the database, so we
+		// can keep retrying it until the database recovers.
+		snapshot := snapshot
+		if err := retry(ctx, fmt.Sprintf("Block %d", snapshot.Block.Height), func() error {
+			return commitSnapshot(ctx, state, iterators, snapshot, "updateLatestSyncHeight")
+		}); err != nil {
+			return fmt.Errorf("StartExtractor: %w", err)
+		}
+
+		metricProcessedHeight.Set(float64(snapshot.Block.Height))
+
+		// Only committed blocks are streamed, so clients never see data that
+		// the REST endpoints cannot serve yet.
+		state.Feed.Publish(feed.Update{
+			Block:        snapshot.Block,
+			Transactions: snapshot.Transactions,
+			Events:       snapshot.Events,
+		})
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("StartExtractor: %w", err)
 	}
+
+	return fmt.Errorf("StartExtractor: block subscription ended")
+}
+
+// commitSnapshot feeds the data fetched for a single height through each of the
+// block iterators, and records the height with the given query. All writes
+// happen in one database transaction, so either the whole block is stored and
+// marked as processed, or nothing is.
+func commitSnapshot(ctx context.Context, state types.State, iterators []namedIterator, snapshot StateSnapshot, heightQuery string) error {
+	tx, err := state.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("commitSnapshot: failed to begin, %w", err)
+	}
+
+	for _, iterator := range iterators {
+		began := time.Now()
+		if err := iterator.Process(ctx, tx, snapshot); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("commitSnapshot: iterator failed, %w", err)
+		}
+		metricIteratorDuration.WithLabelValues(iterator.name).Observe(time.Since(began).Seconds())
+	}
+
+	if _, err := tx.Exec(heightQuery, snapshot.Block.Height); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commitSnapshot: failed to record height, %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commitSnapshot: failed to commit, %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,215 @@
+// This file takes the snapshots scheduled by block iterators. Snapshots are too
+// slow to take inside the transaction of the block that schedules them, so the
+// block only queues a job in its transaction. A runner then polls for queued
+// jobs and takes each one in a transaction of its own, which also marks the job
+// done. A block that fails to commit never leaves a snapshot behind, and a
+// queued snapshot survives the process stopping before it is taken.
+
+package extractor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Kinds of snapshot job, one per block iterator taking snapshots.
+const (
+	jobAccounts = "accounts"
+	jobGenesis  = "genesis"
+)
+
+// Job statuses recorded in the snapshot_jobs table.
+const (
+	jobPending = "pending"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// Failed jobs are retried after jobRetryMinDelay, doubling up to
+// jobRetryMaxDelay, and given up on after jobMaxAttempts.
+const (
+	jobMaxAttempts   = 5
+	jobRetryMinDelay = time.Minute
+	jobRetryMaxDelay = time.Hour
+	jobPollInterval  = 5 * time.Second
+)
+
+// snapshotJob is a queued snapshot waiting to be taken.
+type snapshotJob struct {
+	id       int64
+	block    oasis.Block
+	attempts int
+}
+
+// jobRunner takes the queued jobs of one kind, one at a time and oldest first.
+type jobRunner struct {
+	kind  string
+	state types.State
+	take  func(ctx context.Context, tx *types.Tx, block oasis.Block) error
+
+	mu    sync.Mutex // Held while taking jobs, so only one runs at a time.
+	start sync.Once
+	ctx   context.Context // Set once the runner is started.
+}
+
+func newJobRunner(kind string, state types.State, take func(context.Context, *types.Tx, oasis.Block) error) *jobRunner {
+	return &jobRunner{
+		kind:  kind,
+		state: state,
+		take:  take,
+	}
+}
+
+// queue schedules a snapshot at a block within the block's transaction.
+func (self *jobRunner) queue(tx *types.Tx, block oasis.Block) error {
+	if _, err := tx.Exec("insertSnapshotJob", self.kind, block.Height, block.Time); err != nil {
+		return fmt.Errorf("queue: failed to queue %s snapshot at %d, %w", self.kind, block.Height, err)
+	}
+
+	return nil
+}
+
+// Start takes queued jobs in the background until the context is cancelled,
+// only the first call has any effect.
+func (self *jobRunner) Start(ctx context.Context) {
+	self.start.Do(func() {
+		self.mu.Lock()
+		self.ctx = ctx
+		self.mu.Unlock()
+
+		go func() {
+			ticker := time.NewTicker(jobPollInterval)
+			defer ticker.Stop()
+
+			for {
+				if err := self.runDue(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Snapshot Jobs: %s failed, %v", self.kind, err)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	})
+}
+
+// Wait takes every job that is due right away, returning once none are left.
+// Jobs waiting to be retried after a failure are left for later.
+func (self *jobRunner) Wait() {
+	self.mu.Lock()
+	ctx := self.ctx
+	self.mu.Unlock()
+
+	if ctx == nil {
+		return
+	}
+
+	if err := self.runDue(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Snapshot Jobs: %s failed, %v", self.kind, err)
+	}
+}
+
+// runDue takes due jobs until none are left.
+func (self *jobRunner) runDue(ctx context.Context) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for ctx.Err() == nil {
+		taken, err := self.run(ctx)
+		if err != nil || !taken {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// run takes the next due job, reporting false if there was none. The job is
+// locked by the transaction the snapshot is written in, so processes sharing a
+// database never take the same job twice, and the snapshot and the job being
+// marked done are committed together. A failure discards both and schedules a
+// retry.
+func (self *jobRunner) run(ctx context.Context) (bool, error) {
+	tx, err := self.state.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("run: failed to begin, %w", err)
+	}
+
+	row, err := tx.QueryRow("queryDueSnapshotJob", self.kind)
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("run: query failed, %w", err)
+	}
+
+	var job snapshotJob
+	err = row.Scan(&job.id, &job.block.Height, &job.block.Time, &job.attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return false, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("run: scan failed, %w", err)
+	}
+
+	err = self.take(ctx, tx, job.block)
+	if err == nil {
+		now := time.Now().UTC()
+		_, err = tx.Exec("updateSnapshotJob", job.id, jobDone, job.attempts+1, now, nil, now)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return true, self.fail(ctx, job, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return true, self.fail(ctx, job, err)
+	}
+
+	return true, nil
+}
+
+// fail records a failed attempt at a job, scheduling the next attempt if it
+// has attempts left.
+func (self *jobRunner) fail(ctx context.Context, job snapshotJob, jobErr error) error {
+	attempts := job.attempts + 1
+	status := jobPending
+	if attempts >= jobMaxAttempts {
+		status = jobFailed
+	}
+
+	delay := jobRetryMinDelay
+	for i := 1; i < attempts && delay < jobRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > jobRetryMaxDelay {
+		delay = jobRetryMaxDelay
+	}
+
+	log.Printf("Snapshot Jobs: %s snapshot at %d failed (attempt %d/%d), %v", self.kind, job.block.Height, attempts, jobMaxAttempts, jobErr)
+
+	if _, err := self.state.Dot.ExecContext(ctx, self.state.Db, "updateSnapshotJob",
+		job.id,
+		status,
+		attempts,
+		time.Now().UTC().Add(delay),
+		jobErr.Error(),
+		nil,
+	); err != nil {
+		return fmt.Errorf("fail: failed to update job %d, %w", job.id, err)
+	}
+
+	return nil
+}
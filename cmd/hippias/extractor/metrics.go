@@ -0,0 +1,27 @@
+// Prometheus metrics describing how far along the chain the extractor is, and
+// how long it takes to process each block.
+
+package extractor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricProcessedHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hippias_processed_height",
+		Help: "Height of the most recent block committed by the extractor.",
+	})
+
+	metricSkippedBlocks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hippias_skipped_blocks_total",
+		Help: "Blocks the block subscription skipped after its first block, which the extractor queued separately.",
+	})
+
+	metricIteratorDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hippias_iterator_duration_seconds",
+		Help:    "Time taken by each block iterator to process a block, by its name in the configuration.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"iterator"})
+)
@@ -0,0 +1,150 @@
+// This file implements a pipeline that fetches block data for upcoming heights
+// concurrently. Fetching a height requires several blocking gRPC round-trips,
+// so overlapping them is the main way to speed up catching up with the chain.
+// Block iterators still receive snapshots strictly in height order.
+
+package extractor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// fetchSnapshot fetches all data block iterators need for a single height.
+func fetchSnapshot(ctx context.Context, state types.State, height oasis.Height) (StateSnapshot, error) {
+	api, err := state.Api.AtHeight(ctx, height)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+
+	block, err := api.GetBlock(ctx)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+
+	events, err := api.GetEvents(ctx)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+
+	transactions, err := api.GetTransactions(ctx)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+
+	return StateSnapshot{
+		Block:        block,
+		Events:       events,
+		Transactions: transactions,
+	}, nil
+}
+
+// prefetch reads heights and fetches their snapshots using up to `workers`
+// concurrent fetches. Snapshots are delivered in the same order as the heights
+// were received, regardless of the order the fetches complete in. At most
+// `workers` snapshots are fetched ahead of the consumer. Failed fetches are
+// retried, so a height is never skipped, unless permanent reports the error as
+// one retrying cannot fix.
+//
+// The returned channel is closed once the heights channel is closed and every
+// snapshot is delivered, as soon as the context is cancelled, or at the first
+// height that failed permanently. Once it is closed, the returned function
+// reports that failure, if any.
+func prefetch(ctx context.Context, state types.State, workers int, heights <-chan oasis.Height, permanent func(error) bool) (<-chan StateSnapshot, func() error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Fetches still in flight are abandoned once delivery stops.
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Each height gets its own result channel, queued in height order. The
+	// consumer side drains the queue in order, waiting on each result in turn.
+	ordered := make(chan chan fetchResult, workers)
+	snapshots := make(chan StateSnapshot)
+	semaphore := make(chan struct{}, workers)
+
+	go func() {
+		defer close(ordered)
+		for {
+			var height oasis.Height
+			var ok bool
+			select {
+			case height, ok = <-heights:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			result := make(chan fetchResult, 1)
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case ordered <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(height oasis.Height) {
+				defer func() { <-semaphore }()
+				defer close(result)
+
+				var snapshot StateSnapshot
+				err := retryUnless(ctx, fmt.Sprintf("Fetching Block %d", height), permanent, func() (err error) {
+					snapshot, err = fetchSnapshot(ctx, state, height)
+					return err
+				})
+
+				switch {
+				case err == nil:
+					result <- fetchResult{snapshot: snapshot}
+				case ctx.Err() == nil:
+					result <- fetchResult{err: fmt.Errorf("prefetch: height %d failed, %w", height, err)}
+				}
+			}(height)
+		}
+	}()
+
+	// The first permanent failure, only written by the delivering goroutine
+	// before it closes the snapshots channel.
+	var failed error
+
+	go func() {
+		defer close(snapshots)
+		defer cancel()
+		for result := range ordered {
+			fetched, ok := <-result
+			if !ok {
+				return
+			}
+
+			if fetched.err != nil {
+				failed = fetched.err
+				return
+			}
+
+			select {
+			case snapshots <- fetched.snapshot:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return snapshots, func() error { return failed }
+}
+
+// fetchResult is the outcome of fetching a single height.
+type fetchResult struct {
+	snapshot StateSnapshot
+	err      error
+}
@@ -0,0 +1,39 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+func TestPrefetchStopsAtUnavailableHeight(t *testing.T) {
+	fake := oasis.NewFake(
+		oasis.FakeBlock{Block: oasis.Block{Height: 1}},
+		oasis.FakeBlock{Block: oasis.Block{Height: 2}},
+		oasis.FakeBlock{Block: oasis.Block{Height: 4}},
+	)
+
+	heights := make(chan oasis.Height, 4)
+	for height := oasis.Height(1); height <= 4; height++ {
+		heights <- height
+	}
+	close(heights)
+
+	snapshots, fetchErr := prefetch(context.Background(), types.State{Api: fake}, 2, heights, unavailable)
+
+	var delivered []oasis.Height
+	for snapshot := range snapshots {
+		delivered = append(delivered, snapshot.Block.Height)
+	}
+
+	if len(delivered) != 2 || delivered[0] != 1 || delivered[1] != 2 {
+		t.Fatalf("prefetch: delivered %v, expected the heights before the missing one", delivered)
+	}
+
+	if err := fetchErr(); !errors.Is(err, oasis.ErrNotFound) {
+		t.Fatalf("prefetch: expected the missing height to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// This file decides how the extractor handles failures talking to the node or
+// the database. Both are expected to recover eventually, so rather than
+// skipping data we keep retrying the same work with an increasing delay. The
+// exception is data the node no longer has, such as a pruned height, which no
+// amount of retrying will bring back.
+
+package extractor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Delays between attempts start at retryMinDelay and double up to retryMaxDelay.
+const (
+	retryMinDelay = time.Second
+	retryMaxDelay = time.Minute
+)
+
+// retry calls fn until it succeeds, backing off exponentially between failed
+// attempts. It only gives up when the context is cancelled, in which case the
+// context error is returned.
+func retry(ctx context.Context, what string, fn func() error) error {
+	return retryUnless(ctx, what, nil, fn)
+}
+
+// retryUnless is retry, except that it also gives up on the first error that
+// permanent reports as one retrying cannot fix, returning that error. A nil
+// permanent treats every error as temporary.
+func retryUnless(ctx context.Context, what string, permanent func(error) bool, fn func() error) error {
+	delay := retryMinDelay
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if permanent != nil && permanent(err) {
+			return err
+		}
+
+		log.Printf("%s failed, retrying in %s: %v\n", what, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// unavailable reports whether an error means the node does not have the data
+// asked for, such as a height it has pruned or not reached yet.
+func unavailable(err error) bool {
+	return errors.Is(err, oasis.ErrNotFound)
+}
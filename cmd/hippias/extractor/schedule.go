@@ -0,0 +1,177 @@
+// This file declares a Scheduler interface, which decides on which blocks the
+// SnapshotIterator and GenesisIterator take their snapshots. Schedules are
+// chosen by name in the configuration:
+//
+//   daily   - first block of each day, midnight in the configured timezone.
+//   hourly  - first block of each hour.
+//   blocks  - first block at or past every multiple of the configured blocks.
+//   epoch   - first block of each epoch.
+//   none    - never take snapshots.
+
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Scheduler decides whether a snapshot is due on the next block, given the
+// block before it. The previous block is the zero Block when it is not known,
+// schedules treat that as having crossed their boundary.
+type Scheduler interface {
+	Due(ctx context.Context, api oasis.API, previous, next oasis.Block) (bool, error)
+}
+
+var (
+	_ Scheduler = dailySchedule{}
+	_ Scheduler = hourlySchedule{}
+	_ Scheduler = blocksSchedule{}
+	_ Scheduler = &epochSchedule{}
+	_ Scheduler = neverSchedule{}
+)
+
+// schedules holds a constructor for every schedule that can be selected.
+var schedules = map[string]func(types.ScheduleConfig) (Scheduler, error){
+	"daily": func(config types.ScheduleConfig) (Scheduler, error) {
+		location, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %q is not a known timezone, %w", config.Timezone, err)
+		}
+		return dailySchedule{location}, nil
+	},
+	"hourly": func(config types.ScheduleConfig) (Scheduler, error) {
+		return hourlySchedule{}, nil
+	},
+	"blocks": func(config types.ScheduleConfig) (Scheduler, error) {
+		if config.Blocks < 1 {
+			return nil, fmt.Errorf("blocks must be at least 1 for the blocks schedule")
+		}
+		return blocksSchedule{oasis.Height(config.Blocks)}, nil
+	},
+	"epoch": func(config types.ScheduleConfig) (Scheduler, error) {
+		return &epochSchedule{}, nil
+	},
+	"none": func(config types.ScheduleConfig) (Scheduler, error) {
+		return neverSchedule{}, nil
+	},
+}
+
+// NewScheduler constructs the schedule selected in the configuration.
+func NewScheduler(config types.ScheduleConfig) (Scheduler, error) {
+	create, ok := schedules[config.Schedule]
+	if !ok {
+		known := make([]string, 0, len(schedules))
+		for name := range schedules {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unknown schedule %q, expected any of: %s", config.Schedule, strings.Join(known, ", "))
+	}
+
+	return create(config)
+}
+
+// CheckSchedules verifies every schedule in the configuration is usable.
+func CheckSchedules(config *types.Config) error {
+	if _, err := NewScheduler(config.Snapshots.ScheduleConfig); err != nil {
+		return fmt.Errorf("snapshots: %w", err)
+	}
+
+	if _, err := NewScheduler(config.Genesis.ScheduleConfig); err != nil {
+		return fmt.Errorf("genesis: %w", err)
+	}
+
+	return nil
+}
+
+// Schedules
+// -----------------------------------------------------------------------------
+
+// dailySchedule is due on the first block at or after midnight in its location.
+type dailySchedule struct {
+	location *time.Location
+}
+
+func (self dailySchedule) Due(_ context.Context, _ oasis.API, previous, next oasis.Block) (bool, error) {
+	local := next.Time.In(self.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, self.location)
+	return crossed(previous.Time, next.Time, midnight), nil
+}
+
+// hourlySchedule is due on the first block at or after the start of each hour.
+type hourlySchedule struct{}
+
+func (self hourlySchedule) Due(_ context.Context, _ oasis.API, previous, next oasis.Block) (bool, error) {
+	return crossed(previous.Time, next.Time, next.Time.Truncate(time.Hour)), nil
+}
+
+// blocksSchedule is due on the first block at or past every multiple of its
+// interval, so a snapshot is not lost if the block on the multiple is skipped.
+type blocksSchedule struct {
+	every oasis.Height
+}
+
+func (self blocksSchedule) Due(_ context.Context, _ oasis.API, previous, next oasis.Block) (bool, error) {
+	return next.Height/self.every > previous.Height/self.every, nil
+}
+
+// epochSchedule is due on the first block of every epoch. Epochs are looked up
+// at both heights, the last lookup is kept as blocks arrive in order.
+type epochSchedule struct {
+	height oasis.Height
+	epoch  oasis.Epoch
+}
+
+func (self *epochSchedule) Due(ctx context.Context, api oasis.API, previous, next oasis.Block) (bool, error) {
+	if previous.Height <= 0 {
+		return true, nil
+	}
+
+	before, err := self.epochAt(ctx, api, previous.Height)
+	if err != nil {
+		return false, err
+	}
+
+	after, err := self.epochAt(ctx, api, next.Height)
+	if err != nil {
+		return false, err
+	}
+
+	return after != before, nil
+}
+
+func (self *epochSchedule) epochAt(ctx context.Context, api oasis.API, height oasis.Height) (oasis.Epoch, error) {
+	if height == self.height {
+		return self.epoch, nil
+	}
+
+	epoch, err := api.EpochAt(ctx, height)
+	if err != nil {
+		return 0, err
+	}
+
+	self.height, self.epoch = height, epoch
+	return epoch, nil
+}
+
+// neverSchedule disables snapshots.
+type neverSchedule struct{}
+
+func (self neverSchedule) Due(_ context.Context, _ oasis.API, _, _ oasis.Block) (bool, error) {
+	return false, nil
+}
+
+// Internal Schedule Functions
+// -----------------------------------------------------------------------------
+
+// crossed checks whether a boundary falls after the previous block and at or
+// before the next one.
+func crossed(previous, next, boundary time.Time) bool {
+	return previous.Before(boundary) && !next.Before(boundary)
+}
@@ -0,0 +1,94 @@
+package extractor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	epochtime "github.com/oasisprotocol/oasis-core/go/epochtime/api"
+
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// testChain is a Fake of 30 blocks, 25 minutes apart from 22:10 UTC on the 1st
+// of January, with a new epoch every 5 blocks. Some blocks fall exactly on the
+// hour, so boundaries are tested both when crossed and when landed on.
+func testChain() (*oasis.Fake, oasis.Height) {
+	const length = 30
+	start := time.Date(2020, time.January, 1, 22, 10, 0, 0, time.UTC)
+
+	blocks := make([]oasis.FakeBlock, 0, length)
+	for height := oasis.Height(1); height <= length; height++ {
+		blocks = append(blocks, oasis.FakeBlock{
+			Block: oasis.Block{
+				Height: height,
+				Time:   start.Add(time.Duration(height-1) * 25 * time.Minute),
+			},
+			Epoch: epochtime.EpochTime((height - 1) / 5),
+		})
+	}
+
+	return oasis.NewFake(blocks...), length
+}
+
+func TestNextDue(t *testing.T) {
+	cases := []struct {
+		name      string
+		scheduler Scheduler
+		expected  []oasis.Height
+	}{
+		{"hourly", hourlySchedule{}, []oasis.Height{3, 6, 8, 11, 13, 15, 18, 20, 23, 25, 27, 30}},
+		{"daily", dailySchedule{time.UTC}, []oasis.Height{6}},
+		{"daily in another timezone", dailySchedule{time.FixedZone("UTC-5", -5*60*60)}, []oasis.Height{18}},
+		{"blocks", blocksSchedule{7}, []oasis.Height{7, 14, 21, 28}},
+		{"epoch", &epochSchedule{}, []oasis.Height{6, 11, 16, 21, 26}},
+		{"none", neverSchedule{}, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake, tip := testChain()
+
+			after, err := fake.BlockAt(ctx, 1)
+			if err != nil {
+				t.Fatalf("BlockAt: %v", err)
+			}
+
+			// Follow the schedule from the first block to the tip, as the
+			// catch-up does, collecting every block a snapshot is due on.
+			var found []oasis.Height
+			for {
+				block, due, err := nextDue(ctx, fake, c.scheduler, after, tip)
+				if err != nil {
+					t.Fatalf("nextDue after %d: %v", after.Height, err)
+				}
+				if !due {
+					break
+				}
+
+				found = append(found, block.Height)
+				after = block
+			}
+
+			if !reflect.DeepEqual(found, c.expected) {
+				t.Fatalf("nextDue: due at %v, expected %v", found, c.expected)
+			}
+		})
+	}
+}
+
+func TestNextDueMissingBlock(t *testing.T) {
+	ctx := context.Background()
+	fake, tip := testChain()
+
+	after, err := fake.BlockAt(ctx, 1)
+	if err != nil {
+		t.Fatalf("BlockAt: %v", err)
+	}
+
+	if _, _, err := nextDue(ctx, fake, hourlySchedule{}, after, tip+1); err == nil {
+		t.Fatalf("nextDue: expected an error searching past the last block")
+	}
+}
@@ -0,0 +1,185 @@
+// This file implements a broadcast feed of committed blocks. The extractor
+// publishes every block once it is stored, and streaming clients subscribe to
+// receive them as they arrive. Recent blocks are kept in a ring buffer so that
+// clients reconnecting after a short outage can resume without a gap.
+
+package feed
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+var (
+	// ErrTooOld is returned when subscribing from a height that is no longer
+	// held in the buffer, or that was committed before the feed started.
+	ErrTooOld = errors.New("height is older than the stream buffer")
+
+	// ErrLagged is reported by a subscription that was dropped because its
+	// reader could not keep up with the chain.
+	ErrLagged = errors.New("subscriber fell behind the stream")
+)
+
+// subscriberBuffer is how many blocks a subscriber can fall behind by before
+// it is dropped, on top of any blocks replayed to it when subscribing.
+const subscriberBuffer = 64
+
+// Update is everything the extractor committed for one block.
+type Update struct {
+	Block        oasis.Block
+	Transactions []oasis.Transaction
+	Events       []oasis.StakingEvent
+}
+
+// Feed fans out committed blocks to any number of subscribers.
+type Feed struct {
+	mutex       sync.Mutex
+	buffer      []Update     // Ring buffer of the most recent updates.
+	next        int          // Index in buffer the next update is written to.
+	count       int          // Number of updates held in buffer.
+	first       oasis.Height // First height the feed carries, 0 until known.
+	subscribers map[*Subscription]struct{}
+}
+
+// New creates a Feed that remembers the last `size` blocks for resuming
+// subscribers.
+func New(size int) *Feed {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Feed{
+		buffer:      make([]Update, size),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Start records the first height the feed will carry, blocks committed below
+// it were never published and cannot be resumed from. It has no effect once a
+// height is known, either from an earlier call or from the first update.
+func (self *Feed) Start(height oasis.Height) {
+	if self == nil {
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.first == 0 {
+		self.first = height
+	}
+}
+
+// Publish delivers an update to every subscriber. Updates must be published in
+// height order. Subscribers that are too far behind to accept the update are
+// dropped rather than allowed to stall the extractor.
+func (self *Feed) Publish(update Update) {
+	if self == nil {
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.first == 0 {
+		self.first = update.Block.Height
+	}
+
+	self.buffer[self.next] = update
+	self.next = (self.next + 1) % len(self.buffer)
+	if self.count < len(self.buffer) {
+		self.count++
+	}
+
+	for subscription := range self.subscribers {
+		select {
+		case subscription.updates <- update:
+		default:
+			subscription.err = ErrLagged
+			self.drop(subscription)
+		}
+	}
+}
+
+// Subscribe starts receiving updates. Buffered updates from the `from` height
+// onwards are delivered first, followed by live updates. A `from` of zero
+// only receives live updates. Resuming from a height the feed never carried,
+// or no longer buffers, fails with ErrTooOld, as does any `from` before the
+// feed knows where it starts.
+func (self *Feed) Subscribe(from oasis.Height) (*Subscription, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	// Collect the buffered updates to replay, oldest first.
+	var backlog []Update
+	if from > 0 {
+		oldest := (self.next - self.count + len(self.buffer)) % len(self.buffer)
+		if self.first == 0 || from < self.first {
+			return nil, ErrTooOld
+		}
+
+		if self.count > 0 && self.buffer[oldest].Block.Height > from {
+			return nil, ErrTooOld
+		}
+
+		for i := 0; i < self.count; i++ {
+			update := self.buffer[(oldest+i)%len(self.buffer)]
+			if update.Block.Height >= from {
+				backlog = append(backlog, update)
+			}
+		}
+	}
+
+	subscription := &Subscription{
+		feed:    self,
+		updates: make(chan Update, len(backlog)+subscriberBuffer),
+	}
+
+	for _, update := range backlog {
+		subscription.updates <- update
+	}
+
+	self.subscribers[subscription] = struct{}{}
+	return subscription, nil
+}
+
+// drop removes a subscriber and closes its channel. The mutex must be held.
+func (self *Feed) drop(subscription *Subscription) {
+	if _, ok := self.subscribers[subscription]; ok {
+		delete(self.subscribers, subscription)
+		close(subscription.updates)
+	}
+}
+
+// Subscription
+// -----------------------------------------------------------------------------
+
+// Subscription is a single reader of a Feed.
+type Subscription struct {
+	feed    *Feed
+	updates chan Update
+	err     error
+}
+
+// Updates returns the channel updates are delivered on. It is closed when the
+// subscription is closed or dropped, see Err.
+func (self *Subscription) Updates() <-chan Update {
+	return self.updates
+}
+
+// Err reports why the updates channel was closed, nil if it was closed by the
+// subscriber itself.
+func (self *Subscription) Err() error {
+	self.feed.mutex.Lock()
+	defer self.feed.mutex.Unlock()
+	return self.err
+}
+
+// Close stops the subscription, it is safe to call more than once.
+func (self *Subscription) Close() {
+	self.feed.mutex.Lock()
+	defer self.feed.mutex.Unlock()
+	self.feed.drop(self)
+}
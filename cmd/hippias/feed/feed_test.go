@@ -0,0 +1,63 @@
+package feed
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+func update(height oasis.Height) Update {
+	return Update{Block: oasis.Block{Height: height}}
+}
+
+func TestSubscribeBeforeStart(t *testing.T) {
+	feed := New(4)
+
+	// Until the feed knows where it starts, no height can be resumed from.
+	if _, err := feed.Subscribe(5); !errors.Is(err, ErrTooOld) {
+		t.Fatalf("Subscribe: expected ErrTooOld before the feed started, got %v", err)
+	}
+
+	// After a restart the buffer is empty, but heights committed before the
+	// restart were never published and must not be silently skipped.
+	feed.Start(10)
+	if _, err := feed.Subscribe(5); !errors.Is(err, ErrTooOld) {
+		t.Fatalf("Subscribe: expected ErrTooOld below the start height, got %v", err)
+	}
+
+	subscription, err := feed.Subscribe(10)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer subscription.Close()
+
+	feed.Publish(update(10))
+	if got := <-subscription.Updates(); got.Block.Height != 10 {
+		t.Fatalf("Subscribe: received height %d, expected 10", got.Block.Height)
+	}
+}
+
+func TestSubscribeReplaysBuffer(t *testing.T) {
+	feed := New(2)
+	for height := oasis.Height(1); height <= 3; height++ {
+		feed.Publish(update(height))
+	}
+
+	// Height 1 was published but has since left the buffer.
+	if _, err := feed.Subscribe(1); !errors.Is(err, ErrTooOld) {
+		t.Fatalf("Subscribe: expected ErrTooOld for an evicted height, got %v", err)
+	}
+
+	subscription, err := feed.Subscribe(2)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer subscription.Close()
+
+	for _, expected := range []oasis.Height{2, 3} {
+		if got := <-subscription.Updates(); got.Block.Height != expected {
+			t.Fatalf("Subscribe: replayed height %d, expected %d", got.Block.Height, expected)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// This file stores and reads back genesis snapshots, full dumps of the staking
+// state at some height. Every so often a dump is stored in full as a base, and
+// the dumps after it are stored as merge patches against that base, so that
+// any snapshot can be reconstructed from at most two rows.
+
+package genesis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Snapshot is a genesis snapshot read back from the database.
+type Snapshot struct {
+	Height  oasis.Height
+	Date    time.Time
+	Genesis *oasis.Genesis
+}
+
+// Writer
+// -----------------------------------------------------------------------------
+
+// Writer stores genesis snapshots, diffing each against the most recent base in
+// the database. The decoded base is kept between snapshots, and only replaced
+// once the database shows a newer base was committed, so a snapshot whose
+// transaction is rolled back never becomes the base of the next.
+type Writer struct {
+	mu         sync.Mutex
+	rebase     int          // Diffs stored against a base before the next base.
+	base       interface{}  // Decoded base, nil until one is read.
+	height     oasis.Height // Height of the base.
+	next       interface{}  // Decoded base stored by the last Write, nil if none.
+	nextHeight oasis.Height // Height of the base stored by the last Write.
+}
+
+// NewWriter creates a Writer that stores a new base after rebase diffs, 0 means
+// every snapshot is stored in full.
+func NewWriter(rebase int) *Writer {
+	return &Writer{rebase: rebase}
+}
+
+// Write stores the genesis state taken at a block through a transaction. It is
+// stored as a diff against the current base, unless enough diffs have been
+// stored already or the diff would not save much space, in which case it is
+// stored in full and becomes the new base. A snapshot already stored at the
+// block is kept as it is, as other snapshots may be diffs against it.
+func (self *Writer) Write(tx *types.Tx, block oasis.Block, genesis *oasis.Genesis) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	row, err := tx.QueryRow("queryGenesisSnapshotExists", block.Height)
+	if err != nil {
+		return fmt.Errorf("Writer.Write: failed to query snapshot, %w", err)
+	}
+
+	var exists bool
+	if err := row.Scan(&exists); err != nil {
+		return fmt.Errorf("Writer.Write: failed to read snapshot, %w", err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	diffs, err := self.load(tx)
+	if err != nil {
+		return err
+	}
+
+	document, err := decode(genesis.Serialized)
+	if err != nil {
+		return fmt.Errorf("Writer.Write: failed to decode genesis, %w", err)
+	}
+
+	if self.base != nil && diffs < self.rebase {
+		patch, err := json.Marshal(createPatch(self.base, document))
+		if err != nil {
+			return fmt.Errorf("Writer.Write: failed to encode diff, %w", err)
+		}
+
+		if len(patch) < len(genesis.Serialized)/2 {
+			if _, err := tx.Exec("insertGenesisSnapshot", patch, block.Height, block.Time, self.height); err != nil {
+				return fmt.Errorf("Writer.Write: failed to insert diff, %w", err)
+			}
+			return nil
+		}
+	}
+
+	if _, err := tx.Exec("insertGenesisSnapshot", genesis.Serialized, block.Height, block.Time, nil); err != nil {
+		return fmt.Errorf("Writer.Write: failed to insert snapshot, %w", err)
+	}
+
+	self.next, self.nextHeight = document, block.Height
+	return nil
+}
+
+// load finds the most recent base in the database, along with how many diffs
+// have been stored against it, and makes sure it is the decoded base. It is
+// only read from the database if it is neither the current base nor the one
+// stored by the last Write.
+func (self *Writer) load(tx *types.Tx) (int, error) {
+	next, nextHeight := self.next, self.nextHeight
+	self.next = nil
+
+	row, err := tx.QueryRow("queryGenesisBase")
+	if err != nil {
+		return 0, fmt.Errorf("Writer.load: failed to query base, %w", err)
+	}
+
+	var height oasis.Height
+	var diffs int
+	err = row.Scan(&height, &diffs)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		self.base = nil
+		return 0, nil
+
+	case err != nil:
+		return 0, fmt.Errorf("Writer.load: failed to read base, %w", err)
+
+	case self.base != nil && height == self.height:
+		return diffs, nil
+
+	case next != nil && height == nextHeight:
+		self.base, self.height = next, height
+		return diffs, nil
+	}
+
+	row, err = tx.QueryRow("queryGenesisSnapshotData", height)
+	if err != nil {
+		return 0, fmt.Errorf("Writer.load: failed to query base, %w", err)
+	}
+
+	var encoded []byte
+	if err := row.Scan(&encoded); err != nil {
+		return 0, fmt.Errorf("Writer.load: failed to read base, %w", err)
+	}
+
+	base, err := decode(encoded)
+	if err != nil {
+		return 0, fmt.Errorf("Writer.load: failed to decode base, %w", err)
+	}
+
+	self.base, self.height = base, height
+	return diffs, nil
+}
+
+// Reader
+// -----------------------------------------------------------------------------
+
+// Read reconstructs the most recent genesis snapshot at or below a height. It
+// fails with oasis.ErrNotFound if no snapshot is that old.
+func Read(ctx context.Context, state types.State, height oasis.Height) (Snapshot, error) {
+	row, err := state.Dot.QueryRowContext(ctx, state.Db, "queryGenesisSnapshotAt", height)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Read: failed to query snapshot, %w", err)
+	}
+
+	var snapshot Snapshot
+	var data, base []byte
+	var baseHeight, baseOfBase sql.NullInt64
+	err = row.Scan(&snapshot.Height, &snapshot.Date, &data, &baseHeight, &base, &baseOfBase)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, fmt.Errorf("Read: no snapshot at or below %d, %w", height, oasis.ErrNotFound)
+	}
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Read: failed to read snapshot, %w", err)
+	}
+
+	// Snapshots stored in full need no reconstruction.
+	if !baseHeight.Valid {
+		snapshot.Genesis = &oasis.Genesis{Serialized: data}
+		return snapshot, nil
+	}
+
+	// Diffs must apply to a snapshot stored in full.
+	if base == nil {
+		return Snapshot{}, fmt.Errorf("Read: snapshot at %d is a diff against %d, which is missing", snapshot.Height, baseHeight.Int64)
+	}
+	if baseOfBase.Valid {
+		return Snapshot{}, fmt.Errorf("Read: snapshot at %d is a diff against %d, which is itself a diff", snapshot.Height, baseHeight.Int64)
+	}
+
+	document, err := decode(base)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Read: failed to decode base, %w", err)
+	}
+
+	patch, err := decode(data)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Read: failed to decode diff, %w", err)
+	}
+
+	encoded, err := json.Marshal(applyPatch(document, patch))
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Read: failed to encode snapshot, %w", err)
+	}
+
+	snapshot.Genesis = &oasis.Genesis{Serialized: encoded}
+	return snapshot, nil
+}
@@ -0,0 +1,81 @@
+// This file implements JSON merge patches (RFC 7386), which genesis snapshots
+// are stored as when diffed against a full snapshot. A merge patch describes
+// the changed members of an object: a null member removes it, an object member
+// is merged recursively, and anything else replaces it outright.
+//
+// Merge patches cannot set a member to null, as null means remove. Members that
+// are null in a snapshot are therefore missing once it is reconstructed, which
+// decodes to the same Go value.
+
+package genesis
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// createPatch returns the merge patch that turns from into to.
+func createPatch(from, to interface{}) interface{} {
+	fromObject, fromOK := from.(map[string]interface{})
+	toObject, toOK := to.(map[string]interface{})
+	if !fromOK || !toOK {
+		return to
+	}
+
+	patch := make(map[string]interface{})
+	for key := range fromObject {
+		if _, ok := toObject[key]; !ok {
+			patch[key] = nil
+		}
+	}
+
+	for key, value := range toObject {
+		previous, ok := fromObject[key]
+		switch {
+		case !ok:
+			patch[key] = value
+		case !reflect.DeepEqual(previous, value):
+			patch[key] = createPatch(previous, value)
+		}
+	}
+
+	return patch
+}
+
+// applyPatch applies a merge patch to a document, the document is modified in
+// place where possible.
+func applyPatch(document, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		object = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(object, key)
+			continue
+		}
+		object[key] = applyPatch(object[key], value)
+	}
+
+	return object
+}
+
+// decode parses a JSON document, keeping numbers exactly as written.
+func decode(encoded []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
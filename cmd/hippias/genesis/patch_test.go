@@ -0,0 +1,76 @@
+package genesis
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPatchRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		from, to string
+	}{
+		{"unchanged", `{"a": 1, "b": {"c": "d"}}`, `{"a": 1, "b": {"c": "d"}}`},
+		{"added key", `{"a": 1}`, `{"a": 1, "b": 2}`},
+		{"deleted key", `{"a": 1, "b": 2}`, `{"a": 1}`},
+		{"changed value", `{"a": 1}`, `{"a": 2}`},
+		{"nested change", `{"a": {"b": {"c": 1, "d": 2}}}`, `{"a": {"b": {"c": 1, "d": 3}}}`},
+		{"nested delete", `{"a": {"b": {"c": 1, "d": 2}, "e": 3}}`, `{"a": {"b": {"c": 1}, "e": 3}}`},
+		{"nested add", `{"a": {"b": {}}}`, `{"a": {"b": {"c": {"d": 1}}}}`},
+		{"emptied object", `{"a": {"b": 1, "c": 2}}`, `{"a": {}}`},
+		{"object to scalar", `{"a": {"b": 1}}`, `{"a": "b"}`},
+		{"scalar to object", `{"a": "b"}`, `{"a": {"b": 1}}`},
+		{"arrays replaced", `{"a": [1, 2, 3]}`, `{"a": [1, 3]}`},
+		{"large numbers", `{"a": "0", "b": 18446744073709551615}`, `{"a": "0", "b": 18446744073709551616}`},
+		{"whole document", `{"a": 1}`, `[1, 2]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			from, err := decode([]byte(c.from))
+			if err != nil {
+				t.Fatalf("decode from: %v", err)
+			}
+
+			to, err := decode([]byte(c.to))
+			if err != nil {
+				t.Fatalf("decode to: %v", err)
+			}
+
+			// Patches are stored encoded, so round-trip the patch through JSON
+			// as Write and Read do.
+			encoded, err := json.Marshal(createPatch(from, to))
+			if err != nil {
+				t.Fatalf("encode patch: %v", err)
+			}
+
+			patch, err := decode(encoded)
+			if err != nil {
+				t.Fatalf("decode patch: %v", err)
+			}
+
+			// The base is decoded afresh, as applying modifies it in place.
+			base, err := decode([]byte(c.from))
+			if err != nil {
+				t.Fatalf("decode base: %v", err)
+			}
+
+			if result := applyPatch(base, patch); !reflect.DeepEqual(result, to) {
+				t.Fatalf("applyPatch: got %v, expected %v, patch %s", result, to, encoded)
+			}
+		})
+	}
+}
+
+func TestPatchUnchangedIsEmpty(t *testing.T) {
+	document, err := decode([]byte(`{"a": {"b": [1, 2]}, "c": "d"}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	patch, ok := createPatch(document, document).(map[string]interface{})
+	if !ok || len(patch) != 0 {
+		t.Fatalf("createPatch: expected an empty patch, got %v", patch)
+	}
+}
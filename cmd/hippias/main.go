@@ -2,46 +2,27 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"os"
 
 	"github.com/ChorusOne/Hippias/cmd/hippias/commands"
 	"github.com/ChorusOne/Hippias/cmd/hippias/types"
-	"github.com/golang-migrate/migrate/v4"
-
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
 )
 
-func migrateDB(config *types.Config) {
-	m, err := migrate.New(
-		"file://sql/migrations",
-		config.DatabasePath,
-	)
-
-	if err != nil {
-		log.Fatalf("Migration Failed: %v\n", err)
-	}
-
-	// Migrate all the way until tip.
-	if err := m.Up(); err != nil {
-		if err != migrate.ErrNoChange {
-			log.Fatalf("Migration Failed: %v\n", err)
-		}
-	}
-}
-
 func main() {
-	// Setup CLI Commands & Handlers
-	config := types.ConfigFromEnv()
+	// Setup CLI Commands & Handlers. The configuration is filled in once
+	// the command line has been parsed, see commands.Root.
+	var config types.Config
 	rootCommand := commands.Root(&config)
 	rootCommand.AddCommand(commands.Version(&config))
 	rootCommand.AddCommand(commands.InitDB(&config))
-
-	// Process DB Migrations before entering main logic.
-	migrateDB(&config)
+	rootCommand.AddCommand(commands.Backfill(&config))
+	rootCommand.AddCommand(commands.Webhook(&config))
+	rootCommand.AddCommand(commands.Genesis(&config))
+	rootCommand.AddCommand(commands.Inlet(&config))
+	rootCommand.AddCommand(commands.Config(&config))
 
 	if err := rootCommand.Execute(); err != nil {
 		fmt.Printf("Error: %v", err)
+		os.Exit(1)
 	}
 }
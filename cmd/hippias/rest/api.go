@@ -3,13 +3,15 @@
 package rest
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/ChorusOne/Hippias/cmd/hippias/rest/endpoints"
 	"github.com/ChorusOne/Hippias/cmd/hippias/types"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	"github.com/go-chi/docgen"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	// Riddle me this, what should we call our middleware to disambiguate them
 	// from chi's built in middleware.
@@ -19,29 +21,82 @@ import (
 // StartAPI creates an HTTP server with a REST API for Anthem to consume. This
 // function blocks and so should be spawned as a goroutine.
 func StartAPI(config *types.Config, state types.State) {
+	r, err := Router(config, state)
+	if err != nil {
+		log.Fatalf("StartAPI: %s", err)
+	}
+
+	// Block & Serve
+	http.ListenAndServe(config.ListenAddress, r)
+}
+
+// Router builds the routing table of the REST API, with every endpoint and
+// middleware in place.
+func Router(config *types.Config, state types.State) (chi.Router, error) {
 	// Prepare Endpoints and Chi Router
 	r := chi.NewRouter()
 
+	// Tag every request with an ID, which error responses echo back so
+	// they can be matched with the logs.
+	r.Use(middleware.RequestID)
+
+	// Record the latency of every request, see /metrics.
+	r.Use(riddleware.Instrument)
+
+	// Allow browser applications on the configured origins to call the API.
+	r.Use(riddleware.CORS(config.CORS))
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		riddleware.WriteError(w, r, riddleware.CodeNotFound, "no such endpoint")
+	})
+
 	// Main Routing Table
 	r.Get("/", IndexResponder(state))
-	r.Route("/", func(r chi.Router) {
-		r.Use(middleware.SetHeader("Content-Type", "application/json"))
-		r.Use(riddleware.Paginate)
-		r.Get("/account", endpoints.AccountList(state))
-		r.Get("/account/describe", endpoints.AccountListDescribed(state))
-		r.Get("/account/{accountID}", endpoints.Account(state))
-		r.Get("/account/{accountID}/history", endpoints.AccountHistory(state))
-		r.Get("/account/{accountID}/events", endpoints.EventList(state))
-		r.Get("/account/{accountID}/transactions", endpoints.TransactionList(state))
-		r.Get("/event", endpoints.EventList(state))
-		r.Get("/transaction", endpoints.TransactionList(state))
+	r.Get("/healthz", endpoints.Live(state))
+	r.Get("/readyz", endpoints.Ready(config, state))
+
+	// Data is rate limited per client, health checks and metrics are not.
+	r.Group(func(r chi.Router) {
+		r.Use(riddleware.RateLimit(config.RateLimit))
+
+		// Streams hold the connection open and write their own content
+		// type, so they sit outside the JSON and pagination middleware.
+		r.Get("/stream", endpoints.StreamEvents(state))
+		r.Get("/stream/ws", endpoints.StreamWebSocket(state))
+		r.Get("/account/{accountID}/stream", endpoints.StreamEvents(state))
+		r.Get("/account/{accountID}/stream/ws", endpoints.StreamWebSocket(state))
+
+		r.Route("/", func(r chi.Router) {
+			r.Use(middleware.SetHeader("Content-Type", "application/json"))
+			r.Use(riddleware.Paginate)
+			r.Get("/account", endpoints.AccountList(state))
+			r.Get("/account/describe", endpoints.AccountListDescribed(state))
+			r.Get("/account/{accountID}", endpoints.Account(state))
+			r.Get("/account/{accountID}/delegations", endpoints.AccountDelegations(state))
+			r.Get("/account/{accountID}/history", endpoints.AccountHistory(state))
+			r.Get("/account/{accountID}/events", endpoints.EventList(state))
+			r.Get("/account/{accountID}/transactions", endpoints.TransactionList(state))
+			r.Get("/event", endpoints.EventList(state))
+			r.Get("/transaction", endpoints.TransactionList(state))
+		})
 	})
 
-	// Expose Documentation
-	r.Get("/api", Documentation(r))
+	// Expose Prometheus Metrics
+	r.Handle("/metrics", promhttp.Handler())
 
-	// Block & Serve
-	http.ListenAndServe(":"+config.ListenPort, r)
+	// Expose Documentation, generated from the routes registered above.
+	spec, err := OpenAPI(r)
+	if err != nil {
+		return nil, fmt.Errorf("Router: failed to generate OpenAPI document, %w", err)
+	}
+
+	r.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+	r.Get("/api", Documentation(spec))
+
+	return r, nil
 }
 
 // -----------------------------------------------------------------------------
@@ -50,25 +105,10 @@ func StartAPI(config *types.Config, state types.State) {
 // functions below less verbose.
 type Handler = func(w http.ResponseWriter, r *http.Request)
 
-// IndexResponder acts as a health check.
+// IndexResponder acts as a minimal health check, it succeeds regardless of
+// state. See endpoints.Live and endpoints.Ready for meaningful checks.
 func IndexResponder(_ types.State) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(""))
 	}
 }
-
-// Documentation produces markdown output describing the passed router.
-func Documentation(r *chi.Mux) Handler {
-	documentation := docgen.MarkdownRoutesDoc(r, docgen.MarkdownOpts{
-		ProjectPath: "Hippidaedyvitruvius",
-		Intro:       "Currently exposed API endpoints for Oasis extraction.",
-	})
-
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(
-			"<meta charset='utf-8'>\n" +
-				documentation +
-				"\n<!-- Markdeep: --><style class=\"fallback\">body{visibility:hidden;white-space:pre;font-family:monospace}</style><script src=\"markdeep.min.js\" charset=\"utf-8\"></script><script src=\"https://casual-effects.com/markdeep/latest/markdeep.min.js\" charset=\"utf-8\"></script><script>window.alreadyProcessedMarkdeep||(document.body.style.visibility=\"visible\")</script>",
-		))
-	}
-}
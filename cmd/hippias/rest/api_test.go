@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// testAddress derives a distinct address from a single byte.
+func testAddress(b byte) oasis.Address {
+	var key signature.PublicKey
+	key[0] = b
+	return staking.NewAddress(key)
+}
+
+// testRouter serves a Fake with a ledger of three accounts, fewer than the 20
+// that progress reporting divides accounts into.
+func testRouter(t *testing.T) (http.Handler, []oasis.Address) {
+	addresses := []oasis.Address{testAddress(1), testAddress(2), testAddress(3)}
+	ledger := map[staking.Address]*staking.Account{}
+	for i, address := range addresses {
+		var account staking.Account
+		account.General.Balance = *quantity.NewFromUint64(uint64(100 * (i + 1)))
+		ledger[address] = &account
+	}
+
+	fake := oasis.NewFake(oasis.FakeBlock{
+		Block: oasis.Block{Height: 10, Time: time.Unix(1600000000, 0).UTC()},
+		State: staking.Genesis{Ledger: ledger},
+	})
+
+	router, err := Router(&types.Config{}, types.State{Api: fake})
+	if err != nil {
+		t.Fatalf("Router: %v", err)
+	}
+
+	return router, addresses
+}
+
+func get(t *testing.T, router http.Handler, path string, body interface{}) int {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if body != nil && recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), body); err != nil {
+			t.Fatalf("GET %s: %v, %s", path, err, recorder.Body.String())
+		}
+	}
+
+	return recorder.Code
+}
+
+func TestAccountList(t *testing.T) {
+	router, addresses := testRouter(t)
+
+	var page struct {
+		Data       []string `json:"data"`
+		Height     int64    `json:"height"`
+		Pagination struct {
+			Total uint64 `json:"total"`
+			Next  string `json:"next"`
+		} `json:"pagination"`
+	}
+
+	if code := get(t, router, "/account?limit=2", &page); code != http.StatusOK {
+		t.Fatalf("GET /account: status %d", code)
+	}
+
+	if len(page.Data) != 2 || page.Pagination.Total != uint64(len(addresses)) || page.Height != 10 {
+		t.Fatalf("GET /account: unexpected page %+v", page)
+	}
+
+	if page.Pagination.Next == "" {
+		t.Fatalf("GET /account: expected a link to the next page")
+	}
+}
+
+func TestAccount(t *testing.T) {
+	router, addresses := testRouter(t)
+
+	var account oasis.Account
+	if code := get(t, router, "/account/"+addresses[1].String(), &account); code != http.StatusOK {
+		t.Fatalf("GET /account/{accountID}: status %d", code)
+	}
+
+	if account.Address != addresses[1] || account.Balance != "200" {
+		t.Fatalf("GET /account/{accountID}: unexpected account %+v", account)
+	}
+
+	if code := get(t, router, "/account/"+testAddress(4).String(), nil); code != http.StatusNotFound {
+		t.Fatalf("GET /account/{accountID}: unknown account gave status %d", code)
+	}
+
+	if code := get(t, router, "/account/invalid", nil); code != http.StatusBadRequest {
+		t.Fatalf("GET /account/{accountID}: invalid address gave status %d", code)
+	}
+}
+
+func TestAccountDelegationsAtHeight(t *testing.T) {
+	delegator, validator := testAddress(1), testAddress(2)
+	block := func(height int64, shares uint64) oasis.FakeBlock {
+		return oasis.FakeBlock{
+			Block: oasis.Block{Height: height},
+			State: staking.Genesis{
+				Delegations: map[staking.Address]map[staking.Address]*staking.Delegation{
+					delegator: {validator: {Shares: *quantity.NewFromUint64(shares)}},
+				},
+			},
+		}
+	}
+
+	router, err := Router(&types.Config{}, types.State{Api: oasis.NewFake(block(10, 5), block(11, 7))})
+	if err != nil {
+		t.Fatalf("Router: %v", err)
+	}
+
+	for height, shares := range map[string]string{"": "7", "10": "5", "11": "7"} {
+		var page struct {
+			Data   []oasis.Delegation `json:"data"`
+			Height int64              `json:"height"`
+		}
+
+		path := "/account/" + delegator.String() + "/delegations?block_height=" + height
+		if code := get(t, router, path, &page); code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, code)
+		}
+
+		if len(page.Data) != 1 || page.Data[0].Amount.String() != shares {
+			t.Fatalf("GET %s: unexpected delegations %+v", path, page.Data)
+		}
+	}
+}
+
+func TestPaginationBounds(t *testing.T) {
+	router, _ := testRouter(t)
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=501",
+		"limit=18446744073709551615",
+		"limit=500&page=18446744073709551615",
+		"page=0",
+	} {
+		if code := get(t, router, "/account?"+query, nil); code != http.StatusBadRequest {
+			t.Fatalf("GET /account?%s: status %d, expected %d", query, code, http.StatusBadRequest)
+		}
+	}
+
+	if code := get(t, router, "/account?limit=500", nil); code != http.StatusOK {
+		t.Fatalf("GET /account?limit=500: status %d", code)
+	}
+}
@@ -5,6 +5,7 @@ package endpoints
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
@@ -19,15 +20,35 @@ import (
 type Handler = func(w http.ResponseWriter, r *http.Request)
 
 // AccountList returns a list of addresses in Oasis format, these are returned
-// as a JSON list of strings.
+// as a JSON list of strings. The list is taken at `block_height` if requested.
 func AccountList(state types.State) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
-		accounts := state.Api.Accounts()
+		api, height, err := resolveAPI(r, state)
+		if err != nil {
+			fail(w, r, "AccountList: Failed to resolve height", err)
+			return
+		}
+
+		var accounts []oasis.Address
+		if api != nil {
+			accounts, err = api.Accounts(r.Context())
+		} else if height, err = snapshotHeight(r, state, height); err == nil {
+			accounts, err = snapshotAddresses(r, state, height)
+		}
+
+		if err != nil {
+			fail(w, r, "AccountList: Failed to fetch Accounts", err)
+			return
+		}
+
 		from, to := middleware.PaginateList(r, len(accounts))
 		paginatedAccounts := accounts[from:to]
-		if err := json.NewEncoder(w).Encode(paginatedAccounts); err != nil {
-			log.Printf("%v", err)
-		}
+		middleware.WritePage(w, r, middleware.Page{
+			Data:   paginatedAccounts,
+			Count:  len(paginatedAccounts),
+			Total:  uint64(len(accounts)),
+			Height: height,
+		})
 	}
 }
 
@@ -36,14 +57,36 @@ func AccountList(state types.State) Handler {
 // and metadata along with the account.
 func AccountListDescribed(state types.State) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
-		addresses := state.Api.Accounts()
-		pool, _ := state.Api.Pool()
-		accountsMapped := make([]oasis.Account, len(addresses))
+		api, height, err := resolveAPI(r, state)
+		if err != nil {
+			fail(w, r, "AccountListDescribed: Failed to resolve height", err)
+			return
+		}
+
+		if api == nil {
+			accountListFromSnapshots(state, height)(w, r)
+			return
+		}
+
+		addresses, err := api.Accounts(r.Context())
+		if err != nil {
+			fail(w, r, "AccountListDescribed: Failed to fetch Accounts", err)
+			return
+		}
+
+		pool, err := api.Pool(r.Context())
+		if err != nil {
+			fail(w, r, "AccountListDescribed: Failed to fetch Pool", err)
+			return
+		}
+
 		from, to := middleware.PaginateList(r, len(addresses))
+		accountsMapped := make([]oasis.Account, to-from)
 		for i, address := range addresses[from:to] {
-			mappedAccount, err := state.Api.Account(address)
+			mappedAccount, err := api.Account(r.Context(), address)
 			if err != nil {
-				continue
+				fail(w, r, "AccountListDescribed: Failed to fetch Account", err)
+				return
 			}
 			if len(mappedAccount.Delegations) > 0 {
 				log.Printf("Looking Up: %s\n", address)
@@ -56,23 +99,157 @@ func AccountListDescribed(state types.State) Handler {
 			}
 			accountsMapped[i] = *mappedAccount
 		}
-		if err := json.NewEncoder(w).Encode(accountsMapped); err != nil {
-			log.Println(err)
+
+		middleware.WritePage(w, r, middleware.Page{
+			Data:   accountsMapped,
+			Count:  len(accountsMapped),
+			Total:  uint64(len(addresses)),
+			Height: height,
+		})
+	}
+}
+
+// accountListFromSnapshots serves AccountListDescribed from the most recent
+// stored snapshot at or below a height the node can no longer answer for.
+func accountListFromSnapshots(state types.State, height oasis.Height) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		height, err := snapshotHeight(r, state, height)
+
+		if err != nil {
+			fail(w, r, "AccountListDescribed: Failed to find Snapshot", err)
+			return
+		}
+
+		total, err := countRows(r, state, "queryAccountSnapshotCount", height)
+		if err != nil {
+			fail(w, r, "AccountListDescribed: Failed to count Snapshot", err)
+			return
+		}
+
+		from, to := middleware.PaginateList(r, int(total))
+		results, err := state.Dot.QueryContext(r.Context(), state.Db, "queryAccountSnapshotsAtHeight", height, from, to-from)
+		if err != nil {
+			fail(w, r, "AccountListDescribed: Failed to query Snapshot", err)
+			return
+		}
+		defer results.Close()
+
+		accounts := make([]oasis.Account, 0)
+		for results.Next() {
+			snapshot, err := scanAccountSnapshot(state, results)
+			if err != nil {
+				fail(w, r, "AccountListDescribed: Failed to decode Account", err)
+				return
+			}
+
+			accounts = append(accounts, snapshot.Account)
 		}
+
+		middleware.WritePage(w, r, middleware.Page{
+			Data:   accounts,
+			Count:  len(accounts),
+			Total:  total,
+			Height: height,
+		})
 	}
 }
 
 // Account requests data for a specific account, but also deals with pulling
-// related information and Oasis' weird account encoding.
+// related information and Oasis' weird account encoding. The account is read
+// as of `block_height` if requested, and its `height` field reports the height
+// the data was actually taken from.
 func Account(state types.State) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if accountID := chi.URLParam(r, "accountID"); accountID != "" {
-			key, _ := state.Api.DecodeKey(accountID)
-			accountInfo, _ := state.Api.Account(key)
-			if err := json.NewEncoder(w).Encode(accountInfo); err != nil {
-				log.Println(err)
+		key, ok := accountParam(w, r, state)
+		if !ok {
+			return
+		}
+
+		api, height, err := resolveAPI(r, state)
+		if err != nil {
+			fail(w, r, "Account: Failed to resolve height", err)
+			return
+		}
+
+		var accountInfo *oasis.Account
+		if api != nil {
+			accountInfo, err = api.Account(r.Context(), key)
+		} else {
+			accountInfo, err = accountFromSnapshot(r, state, key.String(), height)
+		}
+
+		if err != nil {
+			fail(w, r, "Account: Failed to fetch Account", err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(accountInfo); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// accountFromSnapshot reads the most recent stored snapshot of an account at
+// or below a height.
+func accountFromSnapshot(r *http.Request, state types.State, address string, height oasis.Height) (*oasis.Account, error) {
+	row, err := state.Dot.QueryRowContext(r.Context(), state.Db, "queryAccountSnapshotAt", address, height)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := scanAccountSnapshot(state, row)
+	if err == sql.ErrNoRows {
+		return nil, errNoSnapshot
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot.Account, nil
+}
+
+// AccountDelegations lists the delegations an account has made, as of
+// `block_height` if requested.
+func AccountDelegations(state types.State) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := accountParam(w, r, state)
+		if !ok {
+			return
+		}
+
+		api, height, err := resolveAPI(r, state)
+		if err != nil {
+			fail(w, r, "AccountDelegations: Failed to resolve height", err)
+			return
+		}
+
+		var delegations []oasis.Delegation
+		if api != nil {
+			delegations, err = api.AccountDelegations(r.Context(), key)
+		} else {
+			var account *oasis.Account
+			if account, err = accountFromSnapshot(r, state, key.String(), height); err == nil {
+				delegations, height = account.Delegations, account.Height
 			}
 		}
+
+		if err != nil {
+			fail(w, r, "AccountDelegations: Failed to fetch Delegations", err)
+			return
+		}
+
+		if delegations == nil {
+			delegations = []oasis.Delegation{}
+		}
+
+		from, to := middleware.PaginateList(r, len(delegations))
+		middleware.WritePage(w, r, middleware.Page{
+			Data:   delegations[from:to],
+			Count:  int(to - from),
+			Total:  uint64(len(delegations)),
+			Height: height,
+		})
 	}
 }
 
@@ -90,74 +267,204 @@ type HistoryAccount struct {
 func AccountHistory(state types.State) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if accountID := chi.URLParam(r, "accountID"); accountID != "" {
-			var snapshotLength int
+			if _, ok := accountParam(w, r, state); !ok {
+				return
+			}
+
+			var snapshotLength uint64
 			var results *sql.Rows
 			var err error
+			pagination := middleware.GetPagination(r)
 
 			// Get AccountHistory Length
-			row, err := state.Dot.QueryRow(state.Db, "queryAccountHistoryLength", accountID)
-			if err != nil {
-				log.Printf("AccountHistory: Failed to query History length, %v", err)
+			synced := syncedHeight(r, state)
+			if snapshotLength, err = countRows(r, state, "queryAccountHistoryLength", accountID); err != nil {
+				fail(w, r, "AccountHistory: Failed to query History length", err)
 				return
 			}
-			row.Scan(&snapshotLength)
 
-			from, to := middleware.PaginateList(r, snapshotLength)
-			if results, err = state.Dot.Query(state.Db, "queryAccountHistory", accountID, from, to); err != nil {
-				log.Printf("AccountHistory: Failed to query History, %v", err)
+			// Snapshots are unique per height, so when paging by cursor only
+			// the height is needed to find where the previous page ended.
+			if cursor := pagination.Cursor; cursor != nil {
+				results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAccountHistoryCursor", accountID, cursor.Height, pagination.Limit+1)
+			} else {
+				from, to := middleware.PaginateList(r, int(snapshotLength))
+				results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAccountHistory", accountID, from, to-from)
+			}
+
+			if err != nil {
+				fail(w, r, "AccountHistory: Failed to query History", err)
 				return
 			}
+			defer results.Close()
 
+			// Cursor queries fetch one row past the limit, which is only
+			// there to tell whether another page follows.
+			var more bool
 			snapshots := make([]HistoryAccount, 0)
 			for results.Next() {
-				var id uint64
-				var address string
-				var balance string
-				var stakingBalance string
-				var debondingBalance string
-				var rewards string
-				var delegations string
-				var isValidator bool
-				var isDelegator bool
-				var height int64
-				var when string
-				if err := results.Scan(&id, &address, &balance, &stakingBalance, &debondingBalance, &rewards, &delegations, &isValidator, &isDelegator, &height, &when); err != nil {
-					log.Printf("AccountHistory: Failed to decode Account, %v", err)
+				if uint64(len(snapshots)) == pagination.Limit {
+					more = true
+					break
+				}
+
+				snapshot, err := scanAccountSnapshot(state, results)
+				if err != nil {
+					fail(w, r, "AccountHistory: Failed to decode Account", err)
 					return
 				}
 
-				// Decode JSON Part
-				var delegationsDecoded []oasis.Delegation
-				json.Unmarshal([]byte(delegations), &delegationsDecoded)
-				key, _ := state.Api.DecodeKey(address)
-
-				var stakingJSON oasis.SharePool
-				var debondingJSON oasis.SharePool
-				json.Unmarshal([]byte(stakingBalance), &stakingJSON)
-				json.Unmarshal([]byte(debondingBalance), &debondingJSON)
-
-				// Decode Account as JSON
-				snapshots = append(snapshots, HistoryAccount{
-					Account: oasis.Account{
-						Address:          key,
-						Balance:          balance,
-						Delegations:      delegationsDecoded,
-						StakedBalance:    &stakingJSON,
-						DebondingBalance: &debondingJSON,
-						Height:           height,
-						Meta: oasis.AccountMeta{
-							IsValidator: isValidator,
-							IsDelegator: isDelegator,
-						},
-					},
-					Date:    when,
-					Rewards: rewards,
-				})
+				snapshots = append(snapshots, snapshot)
+			}
+
+			if err := results.Err(); err != nil {
+				fail(w, r, "AccountHistory: Failed to read History", err)
+				return
+			}
+
+			page := middleware.Page{
+				Data:   snapshots,
+				Count:  len(snapshots),
+				Total:  snapshotLength,
+				Height: synced,
+				More:   more,
 			}
 
-			if err := json.NewEncoder(w).Encode(snapshots); err != nil {
-				log.Println(err)
+			if len(snapshots) > 0 {
+				page.Last = middleware.Cursor{Height: snapshots[len(snapshots)-1].Height}
 			}
+
+			middleware.WritePage(w, r, page)
+		}
+	}
+}
+
+// scanAccountSnapshot decodes a full row of the account_snapshots table.
+func scanAccountSnapshot(state types.State, row interface{ Scan(...interface{}) error }) (HistoryAccount, error) {
+	var id uint64
+	var address string
+	var balance string
+	var stakingBalance string
+	var debondingBalance string
+	var rewards string
+	var delegations string
+	var isValidator bool
+	var isDelegator bool
+	var height int64
+	var when string
+	if err := row.Scan(&id, &address, &balance, &stakingBalance, &debondingBalance, &rewards, &delegations, &isValidator, &isDelegator, &height, &when); err != nil {
+		return HistoryAccount{}, err
+	}
+
+	// Decode JSON Part
+	var delegationsDecoded []oasis.Delegation
+	json.Unmarshal([]byte(delegations), &delegationsDecoded)
+	key, _ := state.Api.DecodeKey(address)
+
+	var stakingJSON oasis.SharePool
+	var debondingJSON oasis.SharePool
+	json.Unmarshal([]byte(stakingBalance), &stakingJSON)
+	json.Unmarshal([]byte(debondingBalance), &debondingJSON)
+
+	// Decode Account as JSON
+	return HistoryAccount{
+		Account: oasis.Account{
+			Address:          key,
+			Balance:          balance,
+			Delegations:      delegationsDecoded,
+			StakedBalance:    &stakingJSON,
+			DebondingBalance: &debondingJSON,
+			Height:           height,
+			Meta: oasis.AccountMeta{
+				IsValidator: isValidator,
+				IsDelegator: isDelegator,
+			},
+		},
+		Date:    when,
+		Rewards: rewards,
+	}, nil
+}
+
+// Point-in-time Reads
+// -----------------------------------------------------------------------------
+
+// errNoSnapshot is returned when neither the node nor the stored snapshots can
+// answer for the height a request asked for.
+var errNoSnapshot = errors.New("no account snapshot at or below the requested height")
+
+// resolveAPI picks where account reads for a request are served from. Without
+// a `block_height` argument that is the tip of the chain. Otherwise the node
+// is asked for its state at that height, and if it no longer has it (pruned
+// nodes only keep recent state) a nil API is returned, telling the caller to
+// read from stored snapshots instead. Any other failure, such as the node
+// being unreachable, is returned so it is reported as such. The height the
+// data will be consistent with is returned when there is no error.
+func resolveAPI(r *http.Request, state types.State) (oasis.API, oasis.Height, error) {
+	requested := oasis.Height(middleware.GetPagination(r).Height)
+	if requested == 0 {
+		block, err := state.Api.GetBlock(r.Context())
+		if err != nil {
+			return nil, 0, err
 		}
+
+		return state.Api, block.Height, nil
 	}
+
+	api, err := state.Api.AtHeight(r.Context(), requested)
+	switch {
+	case errors.Is(err, oasis.ErrNotFound):
+		log.Printf("Height %d unavailable from node, reading snapshots, %v", requested, err)
+		return nil, requested, nil
+
+	case err != nil:
+		return nil, 0, err
+	}
+
+	return api, requested, nil
+}
+
+// snapshotHeight finds the most recent snapshot height at or below a height.
+// Snapshots cover every account at once, so all accounts share this height.
+func snapshotHeight(r *http.Request, state types.State, height oasis.Height) (oasis.Height, error) {
+	row, err := state.Dot.QueryRowContext(r.Context(), state.Db, "queryAccountSnapshotHeight", height)
+	if err != nil {
+		return 0, err
+	}
+
+	var snapshot sql.NullInt64
+	if err := row.Scan(&snapshot); err != nil {
+		return 0, err
+	}
+
+	if !snapshot.Valid {
+		return 0, errNoSnapshot
+	}
+
+	return snapshot.Int64, nil
+}
+
+// snapshotAddresses lists every account stored in a snapshot.
+func snapshotAddresses(r *http.Request, state types.State, height oasis.Height) ([]oasis.Address, error) {
+	results, err := state.Dot.QueryContext(r.Context(), state.Db, "queryAccountSnapshotAddresses", height)
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	addresses := make([]oasis.Address, 0)
+	for results.Next() {
+		var address string
+		if err := results.Scan(&address); err != nil {
+			return nil, err
+		}
+
+		key, err := state.Api.DecodeKey(address)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, key)
+	}
+
+	return addresses, results.Err()
 }
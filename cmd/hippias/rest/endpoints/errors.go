@@ -0,0 +1,65 @@
+// Translate failures into the JSON error responses written by the middleware
+// package, choosing the status from what caused the failure.
+
+package endpoints
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/rest/middleware"
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+	"github.com/go-chi/chi"
+	chimiddleware "github.com/go-chi/chi/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fail responds to a request that could not be served because of err. Missing
+// data becomes a 404 and an unreachable node a 503, anything else is logged and
+// reported as a 500 without leaking the underlying error.
+func fail(w http.ResponseWriter, r *http.Request, where string, err error) {
+	switch {
+	case errors.Is(err, oasis.ErrNotFound), errors.Is(err, errNoSnapshot), errors.Is(err, sql.ErrNoRows):
+		middleware.WriteError(w, r, middleware.CodeNotFound, err.Error())
+
+	case unavailable(err):
+		log.Printf("[%s] %s, %v", chimiddleware.GetReqID(r.Context()), where, err)
+		middleware.WriteError(w, r, middleware.CodeUnavailable, "the Oasis node is unavailable")
+
+	default:
+		log.Printf("[%s] %s, %v", chimiddleware.GetReqID(r.Context()), where, err)
+		middleware.WriteError(w, r, middleware.CodeInternal, "internal server error")
+	}
+}
+
+// unavailable reports whether err was caused by the node being unreachable,
+// or not having synced any state yet.
+func unavailable(err error) bool {
+	if errors.Is(err, oasis.ErrNotSynced) {
+		return true
+	}
+
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return grpcErr.GRPCStatus().Code() == codes.Unavailable
+	}
+
+	return false
+}
+
+// accountParam decodes the `accountID` URL parameter, responding with a 400 if
+// it is not a valid Oasis address.
+func accountParam(w http.ResponseWriter, r *http.Request, state types.State) (oasis.Address, bool) {
+	accountID := chi.URLParam(r, "accountID")
+	address, err := state.Api.DecodeKey(accountID)
+	if err != nil {
+		middleware.WriteError(w, r, middleware.CodeBadRequest, "invalid account address: "+accountID)
+		return address, false
+	}
+
+	return address, true
+}
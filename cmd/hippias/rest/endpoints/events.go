@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/ChorusOne/Hippias/cmd/hippias/rest/middleware"
 	"github.com/ChorusOne/Hippias/cmd/hippias/types"
@@ -22,70 +23,201 @@ func EventList(state types.State) Handler {
 		var err error
 		pagination := middleware.GetPagination(r)
 
-		// Check if we should filter by account.
-		if accountID := chi.URLParam(r, "accountID"); accountID != "" {
-			if results, err = state.Dot.Query(state.Db, "queryAllEventsFiltered", "%"+accountID+"%", (pagination.Page * pagination.Limit), pagination.Limit); err != nil {
-				log.Printf("EventList failed to query events, %v", err)
+		// Check if we should filter by account, and whether the client is
+		// paging by cursor or by page number.
+		accountID := chi.URLParam(r, "accountID")
+		if accountID != "" {
+			if _, ok := accountParam(w, r, state); !ok {
 				return
 			}
+		}
+
+		cursor := pagination.Cursor
+		synced := syncedHeight(r, state)
+
+		var total uint64
+		if accountID != "" {
+			total, err = countRows(r, state, "queryAllEventsFilteredCount", "%"+accountID+"%")
 		} else {
-			log.Printf("EventList: Querying 3\n")
-			if results, err = state.Dot.Query(state.Db, "queryAllEvents", (pagination.Page * pagination.Limit), pagination.Limit); err != nil {
-				log.Printf("EventList failed to query events, %v", err)
-				return
-			}
+			total, err = countRows(r, state, "queryAllEventsCount")
+		}
+
+		if err != nil {
+			fail(w, r, "EventList failed to count events", err)
+			return
 		}
 
+		switch {
+		case accountID != "" && cursor != nil:
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllEventsFilteredCursor", "%"+accountID+"%", cursor.Height, cursor.Index, pagination.Limit+1)
+		case accountID != "":
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllEventsFiltered", "%"+accountID+"%", (pagination.Page * pagination.Limit), pagination.Limit)
+		case cursor != nil:
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllEventsCursor", cursor.Height, cursor.Index, pagination.Limit+1)
+		default:
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllEvents", (pagination.Page * pagination.Limit), pagination.Limit)
+		}
+
+		if err != nil {
+			fail(w, r, "EventList failed to query events", err)
+			return
+		}
+		defer results.Close()
+
+		// Cursor queries fetch one row past the limit, which is only
+		// there to tell whether another page follows.
+		var last middleware.Cursor
+		var more bool
 		events := make([]oasis.StakingEvent, 0)
 		for results.Next() {
+			if uint64(len(events)) == pagination.Limit {
+				more = true
+				break
+			}
+
 			var kind string
-			var when string
+			var when time.Time
 			var height int64
+			var hash sql.NullString
+			var index int
 			var payload string
 
 			// Scan Row into Parts
-			if err := results.Scan(&height, &when, &kind, &payload); err != nil {
-				log.Printf("EventList: Failed to decode Event from DB (%v)", err)
+			if err := results.Scan(&height, &when, &hash, &index, &kind, &payload); err != nil {
+				fail(w, r, "EventList: Failed to decode Event from DB", err)
 				return
 			}
 
-			// Construct Event
-			log.Printf("Event: %v\n", payload)
+			last = middleware.Cursor{Height: height, Index: int64(index)}
+
+			// Construct Event, the payload only carries the event specific
+			// fields so where and when it happened, and its index within the
+			// block, is filled in from the row.
 			switch kind {
 			case "transfer":
 				var decoded oasis.TransferEvent
-				err := json.Unmarshal([]byte(payload), &decoded)
-				log.Printf("Unmarshal Error: %v", err)
+				if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+					log.Printf("EventList: Unmarshal Error: %v", err)
+				}
+				decoded.Height, decoded.Date, decoded.Hash, decoded.Index = height, &when, hash.String, &index
 				events = append(events, oasis.StakingEvent{Transfer: &decoded})
 			case "burn":
 				var decoded oasis.BurnEvent
-				err := json.Unmarshal([]byte(payload), &decoded)
-				log.Printf("Unmarshal Error: %v", err)
+				if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+					log.Printf("EventList: Unmarshal Error: %v", err)
+				}
+				decoded.Height, decoded.Date, decoded.Hash, decoded.Index = height, &when, hash.String, &index
 				events = append(events, oasis.StakingEvent{Burn: &decoded})
 			case "escrow":
 				var decoded oasis.EscrowEvent
-				err := json.Unmarshal([]byte(payload), &decoded)
-				log.Printf("Unmarshal Error: %v", err)
+				if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
+					log.Printf("EventList: Unmarshal Error: %v", err)
+				}
+				switch {
+				case decoded.Add != nil:
+					decoded.Add.Height, decoded.Add.Date, decoded.Add.Hash, decoded.Add.Index = height, &when, hash.String, &index
+				case decoded.Take != nil:
+					decoded.Take.Height, decoded.Take.Date, decoded.Take.Hash, decoded.Take.Index = height, &when, hash.String, &index
+				case decoded.Reclaim != nil:
+					decoded.Reclaim.Height, decoded.Reclaim.Date, decoded.Reclaim.Hash, decoded.Reclaim.Index = height, &when, hash.String, &index
+				}
 				events = append(events, oasis.StakingEvent{Escrow: &decoded})
 			}
 		}
 
-		if err := json.NewEncoder(w).Encode(events); err != nil {
-			log.Printf("%v", err)
+		if err := results.Err(); err != nil {
+			fail(w, r, "EventList: Failed to read Events", err)
+			return
 		}
+
+		middleware.WritePage(w, r, middleware.Page{
+			Data:   events,
+			Count:  len(events),
+			Total:  total,
+			Height: synced,
+			Last:   last,
+			More:   more,
+		})
 	}
 }
 
 type RpcTransaction struct {
-	Hash     string      `json:"hash"`      // Hash of Transaction Bytes
-	Fee      string      `json:"fee"`       // Amount Paid for Tx
-	GasPrice uint64      `json:"gas_price"` // Implied Gas Price
-	Gas      uint64      `json:"gas"`       // Max Gas Allowed
-	Method   string      `json:"method"`    // Which Method does this Tx invoke.
-	Sender   string      `json:"sender"`    // Who submitted the Tx
-	When     string      `json:"date"`      // When the Tx was processed.
-	Height   uint64      `json:"height"`    // Height tX was seen at.
-	Payload  interface{} `json:"data"`      // Actual Payload of TX
+	Hash     string                  `json:"hash"`            // Hash of Transaction Bytes
+	Fee      string                  `json:"fee"`             // Amount Paid for Tx
+	GasPrice uint64                  `json:"gas_price"`       // Implied Gas Price
+	Gas      uint64                  `json:"gas"`             // Max Gas Allowed
+	Method   string                  `json:"method"`          // Which Method does this Tx invoke.
+	Sender   string                  `json:"sender"`          // Who submitted the Tx
+	When     string                  `json:"date"`            // When the Tx was processed.
+	Height   uint64                  `json:"height"`          // Height tX was seen at.
+	Payload  interface{}             `json:"data"`            // Actual Payload of TX
+	Status   string                  `json:"status"`          // success, failed, or unknown.
+	Error    *oasis.TransactionError `json:"error,omitempty"` // Why the Tx failed, if it did.
+	GasUsed  *uint64                 `json:"gas_used"`        // Gas consumed, null if unknown.
+	Events   []oasis.StakingEvent    `json:"events"`          // Staking events emitted by the Tx.
+	Index    uint64                  `json:"index"`           // Position of the Tx within its block.
+}
+
+// scanTransaction decodes a row produced by one of the transaction queries.
+func scanTransaction(row interface{ Scan(...interface{}) error }) (RpcTransaction, error) {
+	var id uint64
+	var method string
+	var payload string
+	var height uint64
+	var when string
+	var sender string
+	var fee string
+	var gas uint64
+	var gasPrice uint64
+	var hash string
+	var status string
+	var errModule sql.NullString
+	var errCode sql.NullInt64
+	var errMessage sql.NullString
+	var gasUsed sql.NullInt64
+	var events sql.NullString
+	var index uint64
+
+	// Scan Row into Parts
+	if err := row.Scan(&id, &when, &fee, &gas, &gasPrice, &hash, &height, &method, &payload, &sender, &status, &errModule, &errCode, &errMessage, &gasUsed, &events, &index); err != nil {
+		return RpcTransaction{}, err
+	}
+
+	var decoded interface{}
+	json.Unmarshal([]byte(payload), &decoded)
+	transaction := RpcTransaction{
+		Fee:      fee,
+		Gas:      gas,
+		GasPrice: gasPrice,
+		Hash:     hash,
+		Height:   height,
+		Method:   method,
+		Payload:  decoded,
+		Sender:   sender,
+		When:     when,
+		Status:   status,
+		Events:   []oasis.StakingEvent{},
+		Index:    index,
+	}
+
+	if errCode.Valid {
+		transaction.Error = &oasis.TransactionError{
+			Module:  errModule.String,
+			Code:    uint32(errCode.Int64),
+			Message: errMessage.String,
+		}
+	}
+
+	if gasUsed.Valid {
+		used := uint64(gasUsed.Int64)
+		transaction.GasUsed = &used
+	}
+
+	if events.Valid {
+		json.Unmarshal([]byte(events.String), &transaction.Events)
+	}
+
+	return transaction, nil
 }
 
 func TransactionList(state types.State) Handler {
@@ -101,98 +233,111 @@ func TransactionList(state types.State) Handler {
 			return
 		}
 
-		// Check if we should filter by account.
-		if accountID := chi.URLParam(r, "accountID"); accountID != "" || txHash != "" {
-			log.Println(accountID)
-			if results, err = state.Dot.Query(state.Db, "queryAllTransactionsFiltered", "%"+accountID+"%", (pagination.Page * pagination.Limit), pagination.Limit); err != nil {
-				log.Printf("TransactionList failed to query events, %v", err)
+		// Check if we should filter by account, and whether the client is
+		// paging by cursor or by page number.
+		accountID := chi.URLParam(r, "accountID")
+		if accountID != "" {
+			if _, ok := accountParam(w, r, state); !ok {
 				return
 			}
+		}
+
+		cursor := pagination.Cursor
+		synced := syncedHeight(r, state)
+
+		var total uint64
+		if accountID != "" {
+			total, err = countRows(r, state, "queryAllTransactionsFilteredCount", "%"+accountID+"%")
 		} else {
-			if results, err = state.Dot.Query(state.Db, "queryAllTransactions", (pagination.Page * pagination.Limit), pagination.Limit); err != nil {
-				log.Printf("TransactionList failed to query events, %v", err)
-				return
-			}
+			total, err = countRows(r, state, "queryAllTransactionsCount")
+		}
+
+		if err != nil {
+			fail(w, r, "TransactionList failed to count transactions", err)
+			return
+		}
+
+		switch {
+		case accountID != "" && cursor != nil:
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllTransactionsFilteredCursor", "%"+accountID+"%", cursor.Height, cursor.Index, pagination.Limit+1)
+		case accountID != "":
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllTransactionsFiltered", "%"+accountID+"%", (pagination.Page * pagination.Limit), pagination.Limit)
+		case cursor != nil:
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllTransactionsCursor", cursor.Height, cursor.Index, pagination.Limit+1)
+		default:
+			results, err = state.Dot.QueryContext(r.Context(), state.Db, "queryAllTransactions", (pagination.Page * pagination.Limit), pagination.Limit)
+		}
+
+		if err != nil {
+			fail(w, r, "TransactionList failed to query events", err)
+			return
 		}
+		defer results.Close()
 
+		// Cursor queries fetch one row past the limit, which is only
+		// there to tell whether another page follows.
+		var more bool
 		transactions := make([]RpcTransaction, 0)
 		for results.Next() {
-			var id uint64
-			var method string
-			var payload string
-			var height uint64
-			var when string
-			var sender string
-			var fee string
-			var gas uint64
-			var gasPrice uint64
-			var hash string
+			if uint64(len(transactions)) == pagination.Limit {
+				more = true
+				break
+			}
 
-			// Scan Row into Parts
-			if err := results.Scan(&id, &when, &fee, &gas, &gasPrice, &hash, &height, &method, &payload, &sender); err != nil {
-				log.Printf("TransactionList: Failed to decode Event from DB (%v)", err)
+			transaction, err := scanTransaction(results)
+			if err != nil {
+				fail(w, r, "TransactionList: Failed to decode Transaction from DB", err)
 				return
 			}
 
-			var decoded interface{}
-			json.Unmarshal([]byte(payload), &decoded)
-			transactions = append(transactions, RpcTransaction{
-				Fee:      fee,
-				Gas:      gas,
-				GasPrice: gasPrice,
-				Hash:     hash,
-				Height:   height,
-				Method:   method,
-				Payload:  decoded,
-				Sender:   sender,
-				When:     when,
-			})
-		}
-
-		if err := json.NewEncoder(w).Encode(transactions); err != nil {
-			log.Printf("%v", err)
+			transactions = append(transactions, transaction)
+		}
+
+		if err := results.Err(); err != nil {
+			fail(w, r, "TransactionList: Failed to read Transactions", err)
+			return
+		}
+
+		page := middleware.Page{
+			Data:   transactions,
+			Count:  len(transactions),
+			Total:  total,
+			Height: synced,
+			More:   more,
+		}
+
+		if len(transactions) > 0 {
+			last := transactions[len(transactions)-1]
+			page.Last = middleware.Cursor{
+				Height: int64(last.Height),
+				Index:  int64(last.Index),
+			}
 		}
+
+		middleware.WritePage(w, r, page)
 	}
 }
 
 func TransactionListByHash(state types.State, txHash string) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Parsing: %v", txHash)
-		row, err := state.Dot.QueryRow(state.Db, "querySpecificTransaction", txHash)
+		row, err := state.Dot.QueryRowContext(r.Context(), state.Db, "querySpecificTransaction", txHash)
 		if err != nil {
-			log.Printf("TransactionListByHash: No Query for Hash: %v\n", txHash)
+			fail(w, r, "TransactionListByHash: Failed to query Transaction", err)
 			return
 		}
 
-		var id uint64
-		var method string
-		var payload string
-		var height uint64
-		var when string
-		var sender string
-		var fee string
-		var gas uint64
-		var gasPrice uint64
-		var hash string
+		transaction, err := scanTransaction(row)
+		if err == sql.ErrNoRows {
+			middleware.WriteError(w, r, middleware.CodeNotFound, "no transaction with hash "+txHash)
+			return
+		}
 
-		if err := row.Scan(&id, &when, &fee, &gas, &gasPrice, &hash, &height, &method, &payload, &sender); err != nil {
-			log.Printf("TransactionListByHash: Failed to decode Event from DB (%v)", err)
+		if err != nil {
+			fail(w, r, "TransactionListByHash: Failed to decode Transaction from DB", err)
 			return
 		}
 
-		var decoded interface{}
-		json.Unmarshal([]byte(payload), &decoded)
-		if err := json.NewEncoder(w).Encode(RpcTransaction{
-			Fee:      fee,
-			Gas:      gas,
-			GasPrice: gasPrice,
-			Hash:     hash,
-			Height:   height,
-			Method:   method,
-			Payload:  decoded,
-			Sender:   sender,
-			When:     when,
-		}); err != nil {
+		if err := json.NewEncoder(w).Encode(transaction); err != nil {
 			log.Printf("%v", err)
 		}
 	}
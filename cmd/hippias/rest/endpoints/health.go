@@ -0,0 +1,129 @@
+// Provide liveness and readiness endpoints for orchestrators. Liveness only
+// shows that the process is serving requests, readiness that it can serve
+// fresh data: the database and node are reachable, the extractor is keeping
+// up with the chain, and the Inlet is still writing to the database. When
+// serving state dumps there is no node or extractor, so only the database and
+// Inlet are checked.
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// healthTimeout bounds how long each readiness check may take.
+const healthTimeout = 2 * time.Second
+
+// Health is the response body of the liveness endpoint.
+type Health struct {
+	Status string `json:"status"` // Always ok.
+}
+
+// Readiness is the response body of the readiness endpoint.
+type Readiness struct {
+	Status string          `json:"status"` // ready, or not_ready if any check failed.
+	Checks ReadinessChecks `json:"checks"`
+}
+
+// ReadinessChecks holds the outcome of every readiness check.
+type ReadinessChecks struct {
+	Database HealthCheck `json:"database"`
+	Node     HealthCheck `json:"node"`
+	Sync     SyncCheck   `json:"sync"`
+	Inlet    HealthCheck `json:"inlet"`
+}
+
+// HealthCheck is the outcome of a single readiness check.
+type HealthCheck struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SyncCheck is the outcome of the sync lag check.
+type SyncCheck struct {
+	HealthCheck
+	Tip       oasis.Height `json:"tip"`       // Height of the node.
+	Processed oasis.Height `json:"processed"` // Height of the extractor.
+	Lag       int64        `json:"lag"`       // Blocks the extractor trails the node by.
+	MaxLag    int64        `json:"max_lag"`   // Lag beyond which the process is not ready.
+}
+
+func writeHealth(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Live responds as long as the process is able to serve requests.
+func Live(_ types.State) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, Health{Status: "ok"})
+	}
+}
+
+// Ready runs every readiness check, responding with a 503 if any fails.
+func Ready(config *types.Config, state types.State) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		defer cancel()
+
+		var checks ReadinessChecks
+
+		// Database
+		checks.Database.OK = true
+		if err := state.Db.PingContext(ctx); err != nil {
+			checks.Database = HealthCheck{Error: err.Error()}
+		}
+
+		// Node Connection, only implementations with a connection report it.
+		checks.Node.OK = true
+		if connector, ok := state.Api.(oasis.Connector); ok && !connector.Connected() {
+			checks.Node = HealthCheck{Error: "not connected to the node"}
+		}
+
+		// Sync Lag
+		sync := &checks.Sync
+		sync.MaxLag = int64(config.MaxSyncLag)
+
+		// State dumps never change and no extractor runs alongside them, so
+		// there is no node to reach and nothing to keep up with.
+		if len(config.StateDumps) > 0 {
+			checks.Node = HealthCheck{OK: true, Detail: "serving state dumps, no node is used"}
+			sync.HealthCheck = HealthCheck{OK: true, Detail: "serving state dumps, the extractor is not running"}
+		} else if block, err := state.Api.GetBlock(ctx); err != nil {
+			sync.Error = "failed to read node height: " + err.Error()
+		} else if row, err := state.Dot.QueryRowContext(ctx, state.Db, "queryLatestSyncHeight"); err != nil {
+			sync.Error = "failed to read processed height: " + err.Error()
+		} else if err := row.Scan(&sync.Processed); err != nil {
+			sync.Error = "failed to read processed height: " + err.Error()
+		} else {
+			sync.Tip = block.Height
+			sync.Lag = sync.Tip - sync.Processed
+			sync.OK = sync.Lag <= sync.MaxLag
+			if !sync.OK {
+				sync.Error = "extractor is too far behind the node"
+			}
+		}
+
+		// Inlet
+		checks.Inlet = HealthCheck{OK: true, Detail: "writing to database"}
+		if oasis.InletToDisk() {
+			checks.Inlet = HealthCheck{Error: "database writes failed, batches are being written to disk"}
+		}
+
+		response, code := Readiness{Status: "ready", Checks: checks}, http.StatusOK
+		if !checks.Database.OK || !checks.Node.OK || !checks.Sync.OK || !checks.Inlet.OK {
+			response.Status, code = "not_ready", http.StatusServiceUnavailable
+		}
+
+		writeHealth(w, code, response)
+	}
+}
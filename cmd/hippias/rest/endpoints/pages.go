@@ -0,0 +1,33 @@
+// Helpers shared by the list endpoints for describing the page they respond
+// with.
+
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+)
+
+// syncedHeight returns the last height the extractor has stored. Everything
+// served from the database is consistent with this height.
+func syncedHeight(r *http.Request, state types.State) int64 {
+	var height int64
+	if row, err := state.Dot.QueryRowContext(r.Context(), state.Db, "queryLatestSyncHeight"); err == nil {
+		row.Scan(&height)
+	}
+
+	return height
+}
+
+// countRows runs a named query that returns a single count.
+func countRows(r *http.Request, state types.State, query string, args ...interface{}) (uint64, error) {
+	row, err := state.Dot.QueryRowContext(r.Context(), state.Db, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	err = row.Scan(&total)
+	return total, err
+}
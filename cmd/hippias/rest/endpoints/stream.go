@@ -0,0 +1,363 @@
+// Provide streaming endpoints, pushing blocks, transactions and staking events
+// to clients as the extractor commits them, over Server-Sent Events or a
+// WebSocket.
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/feed"
+	"github.com/ChorusOne/Hippias/cmd/hippias/rest/middleware"
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+	"github.com/go-chi/chi"
+	"golang.org/x/net/websocket"
+)
+
+// Message types, which can also be passed in `topics` to select what to stream.
+const (
+	TopicBlock       = "block"
+	TopicTransaction = "transaction"
+	TopicEvent       = "event"
+)
+
+// keepAlive is how often an idle stream is sent something, so proxies do not
+// close it.
+const keepAlive = 15 * time.Second
+
+// ping sends an empty WebSocket ping frame, which clients answer without it
+// reaching their message handlers.
+var ping = websocket.Codec{Marshal: func(interface{}) ([]byte, byte, error) {
+	return nil, websocket.PingFrame, nil
+}}
+
+// StreamMessage is a single item pushed to a streaming client.
+type StreamMessage struct {
+	Type   string       `json:"type"`   // One of the Topic constants, or "error".
+	Height oasis.Height `json:"height"` // Height of the block the item belongs to.
+	Date   time.Time    `json:"date"`   // Time of the block the item belongs to.
+	Data   interface{}  `json:"data"`   // Block, Transaction, StakingEvent or Error.
+}
+
+// streamFilter selects which parts of each block a client receives.
+type streamFilter struct {
+	from      oasis.Height
+	topics    map[string]bool
+	addresses []string
+}
+
+// parseStreamFilter reads the stream query parameters, responding with a 400
+// if any is malformed:
+//
+//	from      - Resume from this height, if still buffered.
+//	topics    - Comma separated list of block, transaction and event.
+//	address   - Only stream transactions and events involving these addresses,
+//	            comma separated or repeated.
+//
+// Under /account/{accountID} the account is always used as an address filter.
+func parseStreamFilter(w http.ResponseWriter, r *http.Request, state types.State) (streamFilter, bool) {
+	query := r.URL.Query()
+	filter := streamFilter{
+		topics: map[string]bool{TopicBlock: true, TopicTransaction: true, TopicEvent: true},
+	}
+
+	if from := query.Get("from"); from != "" {
+		height, err := strconv.ParseInt(from, 10, 64)
+		if err != nil || height < 0 {
+			middleware.WriteError(w, r, middleware.CodeBadRequest, "invalid from height: "+from)
+			return filter, false
+		}
+		filter.from = height
+	}
+
+	// Browsers reconnecting an EventSource send the ID of the last message
+	// they received, which is the height of the last complete block.
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		if height, err := strconv.ParseInt(lastID, 10, 64); err == nil {
+			filter.from = height + 1
+		}
+	}
+
+	if topics := query.Get("topics"); topics != "" {
+		filter.topics = map[string]bool{}
+		for _, topic := range strings.Split(topics, ",") {
+			if topic != TopicBlock && topic != TopicTransaction && topic != TopicEvent {
+				middleware.WriteError(w, r, middleware.CodeBadRequest, "invalid topic: "+topic)
+				return filter, false
+			}
+			filter.topics[topic] = true
+		}
+	}
+
+	var addresses []string
+	if accountID := chi.URLParam(r, "accountID"); accountID != "" {
+		addresses = append(addresses, accountID)
+	}
+	for _, param := range query["address"] {
+		addresses = append(addresses, strings.Split(param, ",")...)
+	}
+
+	for _, address := range addresses {
+		if _, err := state.Api.DecodeKey(address); err != nil {
+			middleware.WriteError(w, r, middleware.CodeBadRequest, "invalid account address: "+address)
+			return filter, false
+		}
+		filter.addresses = append(filter.addresses, address)
+	}
+
+	return filter, true
+}
+
+// involves checks whether an item mentions any of the filtered addresses. Like
+// the filtered SQL queries it matches against the serialized item, so every
+// address field of every transaction and event kind is covered.
+func (filter streamFilter) involves(item interface{}) bool {
+	if len(filter.addresses) == 0 {
+		return true
+	}
+
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return false
+	}
+
+	for _, address := range filter.addresses {
+		if strings.Contains(string(encoded), address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// messages converts a committed block into the messages a client receives.
+func (filter streamFilter) messages(update feed.Update) []StreamMessage {
+	var messages []StreamMessage
+	message := func(topic string, data interface{}) StreamMessage {
+		return StreamMessage{
+			Type:   topic,
+			Height: update.Block.Height,
+			Date:   update.Block.Time,
+			Data:   data,
+		}
+	}
+
+	if filter.topics[TopicBlock] {
+		messages = append(messages, message(TopicBlock, update.Block))
+	}
+
+	if filter.topics[TopicTransaction] {
+		for _, transaction := range update.Transactions {
+			if filter.involves(transaction) {
+				messages = append(messages, message(TopicTransaction, transaction))
+			}
+		}
+	}
+
+	if filter.topics[TopicEvent] {
+		for _, event := range update.Events {
+			if filter.involves(event) {
+				messages = append(messages, message(TopicEvent, event))
+			}
+		}
+	}
+
+	return messages
+}
+
+// subscribe starts a feed subscription for the filter, responding with a 410
+// if the client asked to resume from a height that is no longer buffered.
+func subscribe(w http.ResponseWriter, r *http.Request, state types.State, filter streamFilter) (*feed.Subscription, bool) {
+	if state.Feed == nil {
+		middleware.WriteError(w, r, middleware.CodeUnavailable, "streaming is not enabled")
+		return nil, false
+	}
+
+	subscription, err := state.Feed.Subscribe(filter.from)
+	if errors.Is(err, feed.ErrTooOld) {
+		middleware.WriteError(w, r, middleware.CodeGone, fmt.Sprintf("height %d is no longer buffered, fetch it from the list endpoints", filter.from))
+		return nil, false
+	}
+	if err != nil {
+		fail(w, r, "Stream: Failed to subscribe", err)
+		return nil, false
+	}
+
+	return subscription, true
+}
+
+// streamError describes why a subscription ended, if it did not end because
+// the client went away.
+func streamError(subscription *feed.Subscription) StreamMessage {
+	message := "stream closed"
+	if err := subscription.Err(); err != nil {
+		message = err.Error()
+	}
+
+	return StreamMessage{
+		Type: "error",
+		Date: time.Now().UTC(),
+		Data: middleware.Error{
+			Code:    middleware.CodeUnavailable,
+			Message: message,
+		},
+	}
+}
+
+// StreamEvents streams committed blocks as Server-Sent Events. Each message is
+// sent as an event named after its type, and the last message of every block
+// carries the block height as its ID, so a reconnecting EventSource resumes
+// with the next block.
+func StreamEvents(state types.State) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			middleware.WriteError(w, r, middleware.CodeInternal, "streaming is not supported")
+			return
+		}
+
+		filter, ok := parseStreamFilter(w, r, state)
+		if !ok {
+			return
+		}
+
+		subscription, ok := subscribe(w, r, state, filter)
+		if !ok {
+			return
+		}
+		defer subscription.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		write := func(message StreamMessage, id string) error {
+			encoded, err := json.Marshal(message)
+			if err != nil {
+				return err
+			}
+
+			if id != "" {
+				_, err = fmt.Fprintf(w, "event: %s\nid: %s\ndata: %s\n\n", message.Type, id, encoded)
+			} else {
+				_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, encoded)
+			}
+			return err
+		}
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+
+			case update, ok := <-subscription.Updates():
+				if !ok {
+					write(streamError(subscription), "")
+					flusher.Flush()
+					return
+				}
+
+				if update.Block.Height < filter.from {
+					continue
+				}
+
+				messages := filter.messages(update)
+				for i, message := range messages {
+					id := ""
+					if i == len(messages)-1 {
+						id = strconv.FormatInt(update.Block.Height, 10)
+					}
+
+					if err := write(message, id); err != nil {
+						return
+					}
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// StreamWebSocket streams committed blocks over a WebSocket, one JSON encoded
+// StreamMessage per frame. Clients resume after reconnecting by passing the
+// height after the last one they received as `from`.
+func StreamWebSocket(state types.State) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, ok := parseStreamFilter(w, r, state)
+		if !ok {
+			return
+		}
+
+		subscription, ok := subscribe(w, r, state, filter)
+		if !ok {
+			return
+		}
+		defer subscription.Close()
+
+		server := websocket.Server{Handler: func(ws *websocket.Conn) {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+
+			// Nothing is expected from the client, reading only detects
+			// when it goes away.
+			go func() {
+				defer cancel()
+				var discard []byte
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+
+			ticker := time.NewTicker(keepAlive)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case <-ticker.C:
+					if err := ping.Send(ws, nil); err != nil {
+						return
+					}
+
+				case update, ok := <-subscription.Updates():
+					if !ok {
+						websocket.JSON.Send(ws, streamError(subscription))
+						return
+					}
+
+					if update.Block.Height < filter.from {
+						continue
+					}
+
+					for _, message := range filter.messages(update) {
+						if err := websocket.JSON.Send(ws, message); err != nil {
+							return
+						}
+					}
+				}
+			}
+		}}
+
+		server.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,67 @@
+// Cross-origin resource sharing, so browser applications served from other
+// origins can call the API. The API is read-only, so only GET requests and
+// their preflights are allowed.
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+)
+
+// CORS creates a middleware allowing the configured origins to call the API.
+// When no origins are configured it does nothing, and browsers fall back to
+// their same-origin policy.
+func CORS(config types.CORSConfig) func(http.Handler) http.Handler {
+	anyOrigin := false
+	origins := map[string]bool{}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		origins[origin] = true
+	}
+
+	allowedHeaders := strings.Join(config.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(config.MaxAge)
+
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			if origin == "" || !(anyOrigin || origins[origin]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			// Clients read the cursor of the next page from this header.
+			w.Header().Set("Access-Control-Expose-Headers", NextCursorHeader)
+
+			// Answer preflights directly, they never reach a route.
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+				if allowedHeaders != "" {
+					w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+				}
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Keyset pagination. Rather than skipping a number of rows, a cursor names the
+// last row a client has seen, and the next page starts right after it. This
+// keeps pages stable while new blocks are being added, and lets the database
+// seek straight to the right row instead of scanning past an OFFSET.
+
+package middleware
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NextCursorHeader is the response header carrying the cursor of the next page.
+// It is left out when there are no more rows.
+const NextCursorHeader = "X-Next-Cursor"
+
+// Cursor points at a row by the height it was created at and its index within
+// that block. Rows that only exist once per block, such as account snapshots,
+// leave the index at 0. Pages are ordered newest first, so a page holds the
+// rows that come strictly before the cursor.
+type Cursor struct {
+	Height int64
+	Index  int64
+}
+
+// FirstCursor is used when a client asks for cursor pagination without having
+// a cursor yet, it comes before every row so the first page starts at the tip.
+var FirstCursor = Cursor{
+	Height: math.MaxInt64,
+	Index:  math.MaxInt64,
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Encode turns the cursor into the opaque string handed to clients.
+func (cursor Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", cursor.Height, cursor.Index)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor previously produced by Encode.
+func DecodeCursor(encoded string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return Cursor{}, errInvalidCursor
+	}
+
+	height, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+
+	index, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+
+	return Cursor{height, index}, nil
+}
+
+// parseCursor returns the cursor requested by the `cursor` get argument. An
+// empty argument starts from the first page, and a missing one returns nil so
+// handlers fall back to page based pagination.
+func parseCursor(r *http.Request) (*Cursor, error) {
+	values, ok := r.URL.Query()["cursor"]
+	if !ok {
+		return nil, nil
+	}
+
+	if values[0] == "" {
+		cursor := FirstCursor
+		return &cursor, nil
+	}
+
+	cursor, err := DecodeCursor(values[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
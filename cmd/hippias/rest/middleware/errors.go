@@ -0,0 +1,59 @@
+// Every failed request is answered with the same JSON error body, so clients
+// can tell a missing item from a broken server without parsing messages.
+
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Error codes carried in the `code` field of an Error. Each maps onto a single
+// HTTP status.
+const (
+	CodeBadRequest  = "bad_request"
+	CodeNotFound    = "not_found"
+	CodeGone        = "gone"
+	CodeRateLimited = "rate_limited"
+	CodeUnavailable = "unavailable"
+	CodeInternal    = "internal"
+)
+
+// Error is the response body for any request that failed.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// statusCodes maps each error code onto the HTTP status it is sent with.
+var statusCodes = map[string]int{
+	CodeBadRequest:  http.StatusBadRequest,
+	CodeNotFound:    http.StatusNotFound,
+	CodeGone:        http.StatusGone,
+	CodeRateLimited: http.StatusTooManyRequests,
+	CodeUnavailable: http.StatusServiceUnavailable,
+	CodeInternal:    http.StatusInternalServerError,
+}
+
+// WriteError responds with an Error. The request ID assigned by chi's
+// RequestID middleware is included so a report can be matched with the logs.
+func WriteError(w http.ResponseWriter, r *http.Request, code string, message string) {
+	status, ok := statusCodes[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(Error{
+		Code:      code,
+		Message:   message,
+		RequestID: middleware.GetReqID(r.Context()),
+	}); err != nil {
+		log.Printf("WriteError: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Prometheus metrics for the REST API. Requests are labelled by the chi route
+// pattern they matched rather than their path, so every account shares the
+// same series.
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "hippias_http_request_duration_seconds",
+	Help:    "Latency of REST requests, by method, route and status code.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"method", "route", "code"})
+
+// Instrument records the latency of every request. Requests that matched no
+// route are grouped under an empty route label.
+func Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		began := time.Now()
+		wrapped := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(wrapped, r)
+
+		route := ""
+		if ctx := chi.RouteContext(r.Context()); ctx != nil {
+			route = ctx.RoutePattern()
+		}
+
+		// Handlers that never write a header implicitly respond with a 200.
+		status := wrapped.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metricRequestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(status)).
+			Observe(time.Since(began).Seconds())
+	})
+}
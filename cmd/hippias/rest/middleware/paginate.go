@@ -4,6 +4,10 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
 )
@@ -14,13 +18,24 @@ const (
 	Key PaginateKey = PaginateKey("paginate")
 )
 
-// defaultNumber attempts to parse a get argument, returns 0 if the argument is
-// either not present or unparseable.
-func defaultNumber(s string, n uint64) uint64 {
-	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
-		return n
+// MaxLimit is the largest page a client can ask for, so that a single request
+// cannot dump a whole table.
+const MaxLimit = 500
+
+// parseNumber parses a get argument, returning n if the argument is not
+// present at all.
+func parseNumber(r *http.Request, key string, n uint64) (uint64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return n, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
 	}
-	return n
+
+	return parsed, nil
 }
 
 // min exists because Go sucks and doesn't provide one for anything other than
@@ -33,13 +48,42 @@ func min(a, b uint64) uint64 {
 }
 
 // Paginate will look for common pagination get args in a URL and construction
-// a Pagination object that can be used by endpoint handlers.
+// a Pagination object that can be used by endpoint handlers. Requests with
+// malformed arguments are rejected outright.
 func Paginate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		height, err := parseNumber(r, "block_height", 0)
+		if err != nil {
+			WriteError(w, r, CodeBadRequest, err.Error())
+			return
+		}
+
+		limit, err := parseNumber(r, "limit", 50)
+		if err != nil || limit == 0 || limit > MaxLimit {
+			WriteError(w, r, CodeBadRequest, fmt.Sprintf("limit must be between 1 and %d", MaxLimit))
+			return
+		}
+
+		// The offset of the page is passed to the database as a signed
+		// 64-bit integer, so it must not overflow one.
+		page, err := parseNumber(r, "page", 1)
+		if err != nil || page == 0 || page-1 > math.MaxInt64/limit {
+			WriteError(w, r, CodeBadRequest, "page must be a positive integer small enough to page to")
+			return
+		}
+
+		cursor, err := parseCursor(r)
+		if err != nil {
+			WriteError(w, r, CodeBadRequest, err.Error())
+			return
+		}
+
 		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), Key, Pagination{
-			Height: defaultNumber(r.URL.Query().Get("block_height"), 0),
-			Limit:  defaultNumber(r.URL.Query().Get("limit"), 50),
-			Page:   defaultNumber(r.URL.Query().Get("page"), 1) - 1,
+			Height: height,
+			Limit:  limit,
+			Page:   page - 1,
+			Cursor: cursor,
+			Bare:   r.URL.Query().Get("envelope") == "false",
 		})))
 	})
 }
@@ -71,3 +115,57 @@ func PaginateList(r *http.Request, signedLength int) (uint64, uint64) {
 	to := min(from+pagination.Limit, length)
 	return from, to
 }
+
+// WritePage writes a page of results as the response body. By default the page
+// is wrapped in an Envelope, clients that predate it can pass `envelope=false`
+// to receive the bare list instead. When paging by cursor and page.More is set,
+// the next cursor is also sent in the NextCursorHeader.
+func WritePage(w http.ResponseWriter, r *http.Request, page Page) {
+	pagination := GetPagination(r)
+	info := PageInfo{
+		Total: page.Total,
+		Limit: pagination.Limit,
+	}
+
+	if pagination.Cursor != nil {
+		// Handlers fetch a row past the limit to know whether there is a
+		// next page, so a list that ends on a page boundary does not send
+		// clients to an empty page.
+		if page.Count > 0 && page.More {
+			next := page.Last.Encode()
+			w.Header().Set(NextCursorHeader, next)
+			info.Next = pageLink(r, "cursor", next)
+		}
+	} else {
+		info.Page = pagination.Page + 1
+		if (pagination.Page+1)*pagination.Limit < page.Total {
+			info.Next = pageLink(r, "page", strconv.FormatUint(pagination.Page+2, 10))
+		}
+		if pagination.Page > 0 {
+			info.Prev = pageLink(r, "page", strconv.FormatUint(pagination.Page, 10))
+		}
+	}
+
+	var body interface{} = Envelope{
+		Data:       page.Data,
+		Pagination: info,
+		Height:     page.Height,
+	}
+
+	if pagination.Bare {
+		body = page.Data
+	}
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("WritePage: %v", err)
+	}
+}
+
+// pageLink returns the current request URI with one get argument replaced.
+func pageLink(r *http.Request, key, value string) string {
+	link := *r.URL
+	query := link.Query()
+	query.Set(key, value)
+	link.RawQuery = query.Encode()
+	return link.RequestURI()
+}
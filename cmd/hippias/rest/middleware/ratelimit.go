@@ -0,0 +1,81 @@
+// Per-client rate limiting. Each client IP address gets its own token bucket,
+// and requests beyond its limit are rejected with a 429 rather than queued.
+
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+)
+
+// limiterIdle is how long a client can go without making requests before its
+// bucket is forgotten.
+const limiterIdle = 10 * time.Minute
+
+// client tracks the token bucket of a single client.
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimit creates a middleware limiting each client to the configured rate.
+// A rate of zero disables limiting.
+func RateLimit(config types.RateLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if config.RequestsPerSecond <= 0 {
+			return next
+		}
+
+		var mutex sync.Mutex
+		clients := map[string]*client{}
+		lastSweep := time.Now()
+		retryAfter := strconv.Itoa(int(math.Ceil(1 / config.RequestsPerSecond)))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			address, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				address = r.RemoteAddr
+			}
+
+			mutex.Lock()
+			now := time.Now()
+
+			// Forget idle clients now and then, so the map does not grow
+			// with every address ever seen.
+			if now.Sub(lastSweep) > limiterIdle {
+				for key, seen := range clients {
+					if now.Sub(seen.lastSeen) > limiterIdle {
+						delete(clients, key)
+					}
+				}
+				lastSweep = now
+			}
+
+			c, ok := clients[address]
+			if !ok {
+				c = &client{limiter: rate.NewLimiter(rate.Limit(config.RequestsPerSecond), config.Burst)}
+				clients[address] = c
+			}
+			c.lastSeen = now
+			allowed := c.limiter.AllowN(now, 1)
+			mutex.Unlock()
+
+			if !allowed {
+				w.Header().Set("Retry-After", retryAfter)
+				WriteError(w, r, CodeRateLimited, fmt.Sprintf("rate limit of %g requests per second exceeded", config.RequestsPerSecond))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
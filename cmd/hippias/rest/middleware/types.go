@@ -4,8 +4,40 @@ package middleware
 type PaginateKey string
 
 // Pagination wraps the common values required to paginate query results.
+// When Cursor is set the client asked for keyset pagination, and Page should
+// be ignored.
 type Pagination struct {
-	Height uint64 `json:"block_height"`
-	Limit  uint64 `json:"limit"`
-	Page   uint64 `json:"page"`
+	Height uint64  `json:"block_height"`
+	Limit  uint64  `json:"limit"`
+	Page   uint64  `json:"page"`
+	Cursor *Cursor `json:"-"`
+	Bare   bool    `json:"-"` // Respond with a bare list, without an Envelope.
+}
+
+// Envelope wraps a page of results from a list endpoint, together with what a
+// client needs to fetch the rest of the list.
+type Envelope struct {
+	Data       interface{} `json:"data"`
+	Pagination PageInfo    `json:"pagination"`
+	Height     int64       `json:"height"` // Height the data is consistent with.
+}
+
+// PageInfo describes where a page sits within the full list. Next and Prev are
+// links to the neighbouring pages, left empty when there is no such page.
+type PageInfo struct {
+	Total uint64 `json:"total"`
+	Page  uint64 `json:"page,omitempty"` // Unset when paging by cursor.
+	Limit uint64 `json:"limit"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+// Page is everything WritePage needs to know about a page of results.
+type Page struct {
+	Data   interface{} // The results themselves, as a slice.
+	Count  int         // Number of results in Data.
+	Total  uint64      // Number of results across all pages.
+	Height int64       // Height the data is consistent with.
+	Last   Cursor      // Position of the final result, used when paging by cursor.
+	More   bool        // Whether results follow this page, used when paging by cursor.
 }
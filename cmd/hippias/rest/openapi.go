@@ -0,0 +1,548 @@
+// This file generates an OpenAPI 3 description of the REST API. Paths come from
+// walking the chi router, so every mounted route is listed, while parameters
+// and response schemas come from the operations table below, with schemas
+// derived by reflecting over the response types.
+
+package rest
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/rest/endpoints"
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+	"github.com/go-chi/chi"
+
+	riddleware "github.com/ChorusOne/Hippias/cmd/hippias/rest/middleware"
+)
+
+// Operations
+// -----------------------------------------------------------------------------
+
+// operation describes a route beyond what the router itself knows.
+type operation struct {
+	ID          string      // Unique name, used by client generators.
+	Summary     string      // One line description.
+	Response    interface{} // Zero value of the type returned on success.
+	Paged       bool        // Response is a list wrapped in an Envelope.
+	AtHeight    bool        // Honours the block_height parameter.
+	QueryParams []parameter // Parameters specific to this route.
+	Stream      bool        // Route streams messages until the client leaves.
+	ContentType string      // Media type of the response, JSON if empty.
+}
+
+// parameter is a query parameter accepted by a route.
+type parameter struct {
+	Name        string
+	Description string
+	Type        string
+}
+
+// paginationParams are accepted by every paged route.
+var paginationParams = []parameter{
+	{"limit", fmt.Sprintf("Number of results per page, from 1 to %d, defaults to 50.", riddleware.MaxLimit), "integer"},
+	{"page", "Page to return, starting at 1. Ignored when paging by cursor.", "integer"},
+	{"cursor", "Page by cursor instead of page number. Pass it empty to start from the newest result, then pass back the `next` cursor of each page.", "string"},
+	{"envelope", "Pass `false` to receive the bare list, without the pagination envelope.", "string"},
+}
+
+// streamParams are accepted by every streaming route.
+var streamParams = []parameter{
+	{"from", "Replay buffered blocks from this height before streaming new ones.", "integer"},
+	{"topics", "Comma separated types of message to stream, any of `block`, `transaction` and `event`.", "string"},
+	{"address", "Only stream transactions and events involving these addresses, comma separated or repeated.", "string"},
+}
+
+// heightParam is accepted by routes that can answer for a past height.
+var heightParam = parameter{"block_height", "Answer as of this height rather than the tip of the chain.", "integer"}
+
+// operations is keyed by method and route pattern. Routes missing here are
+// still documented, just without a response schema.
+var operations = map[string]operation{
+	"GET /": {
+		ID:      "Index",
+		Summary: "Empty response, kept for compatibility. Prefer /healthz and /readyz.",
+	},
+	"GET /healthz": {
+		ID:       "Live",
+		Summary:  "Liveness check, succeeds whenever the process is serving requests.",
+		Response: endpoints.Health{},
+	},
+	"GET /readyz": {
+		ID:       "Ready",
+		Summary:  "Readiness check, fails with a 503 and the same body if the database or node is unreachable, the extractor is too far behind, or the Inlet has failed over to disk.",
+		Response: endpoints.Readiness{},
+	},
+	"GET /metrics": {
+		ID:      "Metrics",
+		Summary: "Prometheus metrics, in the Prometheus text format.",
+	},
+	"GET /account": {
+		ID:       "AccountList",
+		Summary:  "List the address of every account.",
+		Response: []oasis.Address{},
+		Paged:    true,
+		AtHeight: true,
+	},
+	"GET /account/describe": {
+		ID:       "AccountListDescribed",
+		Summary:  "List every account, including balances and delegations.",
+		Response: []oasis.Account{},
+		Paged:    true,
+		AtHeight: true,
+	},
+	"GET /account/{accountID}": {
+		ID:       "Account",
+		Summary:  "Get the balances and delegations of an account.",
+		Response: oasis.Account{},
+		AtHeight: true,
+	},
+	"GET /account/{accountID}/delegations": {
+		ID:       "AccountDelegations",
+		Summary:  "List the delegations an account has made.",
+		Response: []oasis.Delegation{},
+		Paged:    true,
+		AtHeight: true,
+	},
+	"GET /account/{accountID}/history": {
+		ID:       "AccountHistory",
+		Summary:  "List the stored snapshots of an account, oldest first when paging by page number and newest first when paging by cursor.",
+		Response: []endpoints.HistoryAccount{},
+		Paged:    true,
+	},
+	"GET /account/{accountID}/events": {
+		ID:       "AccountEventList",
+		Summary:  "List the staking events involving an account, newest first.",
+		Response: []oasis.StakingEvent{},
+		Paged:    true,
+	},
+	"GET /account/{accountID}/transactions": {
+		ID:       "AccountTransactionList",
+		Summary:  "List the transactions involving an account.",
+		Response: []endpoints.RpcTransaction{},
+		Paged:    true,
+	},
+	"GET /event": {
+		ID:       "EventList",
+		Summary:  "List every staking event, newest first.",
+		Response: []oasis.StakingEvent{},
+		Paged:    true,
+	},
+	"GET /stream": {
+		ID:          "StreamEvents",
+		Summary:     "Stream blocks, transactions and staking events as Server-Sent Events.",
+		Response:    endpoints.StreamMessage{},
+		ContentType: "text/event-stream",
+		Stream:      true,
+	},
+	"GET /stream/ws": {
+		ID:       "StreamWebSocket",
+		Summary:  "Stream blocks, transactions and staking events over a WebSocket, one message per frame.",
+		Response: endpoints.StreamMessage{},
+		Stream:   true,
+	},
+	"GET /account/{accountID}/stream": {
+		ID:          "AccountStreamEvents",
+		Summary:     "Stream blocks, and the transactions and staking events involving an account, as Server-Sent Events.",
+		Response:    endpoints.StreamMessage{},
+		ContentType: "text/event-stream",
+		Stream:      true,
+	},
+	"GET /account/{accountID}/stream/ws": {
+		ID:       "AccountStreamWebSocket",
+		Summary:  "Stream blocks, and the transactions and staking events involving an account, over a WebSocket.",
+		Response: endpoints.StreamMessage{},
+		Stream:   true,
+	},
+	"GET /transaction": {
+		ID:       "TransactionList",
+		Summary:  "List every transaction, or look one up by hash.",
+		Response: []endpoints.RpcTransaction{},
+		Paged:    true,
+		QueryParams: []parameter{
+			{"hash", "Return only the transaction with this hash, as a single object rather than a list.", "string"},
+		},
+	},
+}
+
+// Document
+// -----------------------------------------------------------------------------
+
+var routeParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// OpenAPI builds the OpenAPI document for every route mounted on the router.
+func OpenAPI(r chi.Routes) ([]byte, error) {
+	schemas := &schemaBuilder{components: map[string]interface{}{}, types: map[string]reflect.Type{}}
+	errorRef := schemas.schema(reflect.TypeOf(riddleware.Error{}))
+	paths := map[string]map[string]interface{}{}
+
+	err := chi.Walk(r, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.Replace(route, "/*/", "/", -1)
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+
+		op, known := operations[method+" "+route]
+		if !known {
+			op.Summary = "Undocumented."
+		}
+
+		// Path parameters are taken from the route pattern itself.
+		parameters := []interface{}{}
+		for _, match := range routeParam.FindAllStringSubmatch(route, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+
+		query := op.QueryParams
+		if op.AtHeight {
+			query = append(query, heightParam)
+		}
+		if op.Paged {
+			query = append(query, paginationParams...)
+		}
+		if op.Stream {
+			query = append(query, streamParams...)
+		}
+		for _, param := range query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"description": param.Description,
+				"schema":      map[string]interface{}{"type": param.Type},
+			})
+		}
+
+		// Success response, if the route has a known response type.
+		success := map[string]interface{}{"description": "Success."}
+		if op.Response != nil {
+			var body map[string]interface{}
+			if op.Paged {
+				body = schemas.page(reflect.TypeOf(op.Response).Elem())
+			} else {
+				body = schemas.schema(reflect.TypeOf(op.Response))
+			}
+
+			contentType := op.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			success["content"] = map[string]interface{}{
+				contentType: map[string]interface{}{"schema": body},
+			}
+		}
+
+		failure := func(description string) map[string]interface{} {
+			return map[string]interface{}{
+				"description": description,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errorRef},
+				},
+			}
+		}
+
+		operation := map[string]interface{}{
+			"summary":    op.Summary,
+			"parameters": parameters,
+			"responses": map[string]interface{}{
+				"200": success,
+				"400": failure("Malformed address or parameter."),
+				"404": failure("No such account, transaction, or snapshot."),
+				"500": failure("Internal server error."),
+				"503": failure("The Oasis node is unavailable."),
+			},
+		}
+		if op.ID != "" {
+			operation["operationId"] = op.ID
+		}
+		if op.Stream {
+			operation["responses"].(map[string]interface{})["410"] = failure("The height to resume from is no longer buffered.")
+		}
+
+		openAPIPath := routeParam.ReplaceAllString(route, "{$1}")
+		if paths[openAPIPath] == nil {
+			paths[openAPIPath] = map[string]interface{}{}
+		}
+		paths[openAPIPath][strings.ToLower(method)] = operation
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Hippias",
+			"description": "Historical account and staking data for the Oasis network.",
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+		},
+	}, "", "  ")
+}
+
+// Schemas
+// -----------------------------------------------------------------------------
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaBuilder derives JSON schemas from Go types the same way encoding/json
+// would serialize them. Named structs are stored once as components and then
+// referenced.
+type schemaBuilder struct {
+	components map[string]interface{}
+	types      map[string]reflect.Type
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// page describes the Envelope a paged list of items is wrapped in.
+func (b *schemaBuilder) page(item reflect.Type) map[string]interface{} {
+	itemSchema := b.schema(item)
+	name := "Page"
+	if item.Name() != "" {
+		name = item.Name() + "Page"
+	}
+
+	if _, ok := b.components[name]; !ok {
+		b.components[name] = map[string]interface{}{
+			"type":     "object",
+			"required": []string{"data", "pagination", "height"},
+			"properties": map[string]interface{}{
+				"data":       map[string]interface{}{"type": "array", "items": itemSchema},
+				"pagination": b.schema(reflect.TypeOf(riddleware.PageInfo{})),
+				"height":     map[string]interface{}{"type": "integer", "format": "int64"},
+			},
+		}
+	}
+
+	return ref(name)
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(textMarshalerType), reflect.PtrTo(t).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		inner := b.schema(t.Elem())
+		if _, isRef := inner["$ref"]; isRef {
+			return map[string]interface{}{"nullable": true, "allOf": []interface{}{inner}}
+		}
+		inner["nullable"] = true
+		return inner
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+
+		// Types from different packages may share a name.
+		name := t.Name()
+		if seen, ok := b.types[name]; ok && seen != t {
+			name = strings.Title(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + name
+		}
+
+		if _, ok := b.types[name]; !ok {
+			b.types[name] = t
+			b.components[name] = b.object(t)
+		}
+
+		return ref(name)
+	}
+
+	// Interfaces, and anything else, can hold any JSON value.
+	return map[string]interface{}{}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	b.fields(t, properties, &required)
+
+	object := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+
+	return object
+}
+
+// fields collects the JSON fields of a struct, flattening embedded structs the
+// way encoding/json does.
+func (b *schemaBuilder) fields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		omitEmpty := strings.Contains(tag, ",omitempty")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.fields(embedded, properties, required)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schema(field.Type)
+		if !omitEmpty && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// Viewer
+// -----------------------------------------------------------------------------
+
+// Documentation serves the OpenAPI document as a page readable in a browser.
+// Everything it needs is inline, so it works without internet access.
+func Documentation(spec []byte) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(strings.Replace(viewerPage, "{{SPEC}}", string(spec), 1)))
+	}
+}
+
+const viewerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Hippias API</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #222; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: .2em; }
+.op { border: 1px solid #ddd; border-radius: 4px; margin: 1em 0; padding: .5em 1em; }
+.method { font-weight: bold; text-transform: uppercase; color: #fff; background: #2b7bb9; padding: 0 .4em; border-radius: 3px; }
+code, pre { background: #f6f6f6; }
+pre { padding: .5em; overflow-x: auto; }
+table { border-collapse: collapse; }
+td, th { text-align: left; padding: .2em .8em .2em 0; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Hippias API</h1>
+<p>The machine readable document is served at <a href="openapi.json">/openapi.json</a>.</p>
+<div id="paths"></div>
+<h2>Schemas</h2>
+<div id="schemas"></div>
+<script id="spec" type="application/json">{{SPEC}}</script>
+<script>
+var spec = JSON.parse(document.getElementById("spec").textContent);
+function el(tag, text) { var e = document.createElement(tag); if (text) e.textContent = text; return e; }
+function typeName(s) {
+  if (!s) return "";
+  if (s.$ref) return s.$ref.split("/").pop();
+  if (s.allOf) return typeName(s.allOf[0]) + (s.nullable ? " | null" : "");
+  if (s.type === "array") return typeName(s.items) + "[]";
+  return (s.type || "any") + (s.format ? " (" + s.format + ")" : "") + (s.nullable ? " | null" : "");
+}
+var paths = document.getElementById("paths");
+Object.keys(spec.paths).sort().forEach(function (path) {
+  Object.keys(spec.paths[path]).forEach(function (method) {
+    var op = spec.paths[path][method], div = el("div");
+    div.className = "op";
+    var title = el("h3"), m = el("span", method);
+    m.className = "method";
+    title.appendChild(m);
+    title.appendChild(document.createTextNode(" " + path));
+    div.appendChild(title);
+    div.appendChild(el("p", op.summary));
+    if (op.parameters.length) {
+      var table = el("table");
+      op.parameters.forEach(function (p) {
+        var row = el("tr");
+        row.appendChild(el("td", p.name));
+        row.appendChild(el("td", p.in));
+        row.appendChild(el("td", typeName(p.schema)));
+        row.appendChild(el("td", p.description || ""));
+        table.appendChild(row);
+      });
+      div.appendChild(table);
+    }
+    var ok = op.responses["200"].content;
+    if (ok) Object.keys(ok).forEach(function (type) {
+      div.appendChild(el("p", "Returns " + typeName(ok[type].schema) + " as " + type));
+    });
+    paths.appendChild(div);
+  });
+});
+var schemas = document.getElementById("schemas");
+Object.keys(spec.components.schemas).sort().forEach(function (name) {
+  var s = spec.components.schemas[name];
+  schemas.appendChild(el("h3", name));
+  var table = el("table");
+  Object.keys(s.properties || {}).sort().forEach(function (prop) {
+    var row = el("tr");
+    row.appendChild(el("td", prop));
+    row.appendChild(el("td", typeName(s.properties[prop])));
+    row.appendChild(el("td", (s.required || []).indexOf(prop) >= 0 ? "required" : ""));
+    table.appendChild(row);
+  });
+  schemas.appendChild(table);
+});
+</script>
+</body>
+</html>
+`
@@ -1,32 +1,283 @@
+// Configuration is layered: built in defaults are overridden by a YAML or TOML
+// file, then by HIPPIAS_* environment variables, then by command line flags.
+// Every setting has a key, used as-is in files, upper cased with dots replaced
+// by underscores in the environment (`inlet.batch_size` is read from
+// HIPPIAS_INLET_BATCH_SIZE), and with underscores and dots replaced by dashes
+// for flags (`--inlet-batch-size`). The database is read from HIPPIAS_DB, and
+// HIPPIAS_PORT is still accepted in place of HIPPIAS_LISTEN.
+
 package types
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
 
 // Config is used to wrap up runtime choices to pass around the app.
 type Config struct {
-	DailySnapshots    bool   // Take snapshot at the first observed timestamp of the day.
-	DatabasePath      string // Note: Postgres expected.
-	ListenPort        string // Default port is 10100 if none provided.
-	OasisSocket       string // UNIX Socket for Oasis gRPC
-	SnapshotFrequency int    // 0 means never.
-}
-
-// ConfigFromEnv produces a config option from the currently available
-// environment variables. Hippias does not yet support other configuration
-// methods like files.
-func ConfigFromEnv() Config {
-	defaultEnv := func(key, def string) string {
-		if val := os.Getenv(key); val != "" {
-			return val
+	DatabasePath    string   `mapstructure:"database" yaml:"database"`                 // Note: Postgres expected.
+	ListenAddress   string   `mapstructure:"listen" yaml:"listen"`                     // Address the REST server listens on.
+	MaxSyncLag      int      `mapstructure:"max_sync_lag" yaml:"max_sync_lag"`         // Blocks the extractor may trail the node by before it is not ready.
+	OasisSocket     string   `mapstructure:"socket" yaml:"socket"`                     // UNIX Socket for Oasis gRPC
+	PrefetchWorkers int      `mapstructure:"prefetch_workers" yaml:"prefetch_workers"` // Number of heights fetched concurrently ahead of the extractor.
+	StreamBuffer    int      `mapstructure:"stream_buffer" yaml:"stream_buffer"`       // Number of recent blocks streaming clients can resume from.
+	Iterators       []string `mapstructure:"iterators" yaml:"iterators"`               // Block iterators the extractor runs, by name.
+	StateDumps      []string `mapstructure:"state_dumps" yaml:"state_dumps"`           // State dump files or directories to serve instead of a node.
+
+	Snapshots SnapshotConfig  `mapstructure:"snapshots" yaml:"snapshots"`
+	Genesis   GenesisConfig   `mapstructure:"genesis" yaml:"genesis"`
+	Inlet     InletConfig     `mapstructure:"inlet" yaml:"inlet"`
+	CORS      CORSConfig      `mapstructure:"cors" yaml:"cors"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit" yaml:"rate_limit"`
+}
+
+// ScheduleConfig chooses the blocks a periodic snapshot is taken at.
+type ScheduleConfig struct {
+	Schedule string `mapstructure:"schedule" yaml:"schedule"` // One of daily, hourly, blocks, epoch or none.
+	Timezone string `mapstructure:"timezone" yaml:"timezone"` // Timezone days start in for the daily schedule.
+	Blocks   int    `mapstructure:"blocks" yaml:"blocks"`     // Blocks between snapshots for the blocks schedule.
+}
+
+// SnapshotConfig decides when account snapshots are taken.
+type SnapshotConfig struct {
+	ScheduleConfig `mapstructure:",squash" yaml:",inline"`
+	CatchUp        int `mapstructure:"catch_up" yaml:"catch_up"` // Most missed snapshots taken on restart, 0 means none.
+}
+
+// GenesisConfig decides when full staking state dumps are taken, and how they
+// are stored.
+type GenesisConfig struct {
+	ScheduleConfig `mapstructure:",squash" yaml:",inline"`
+	Rebase         int `mapstructure:"rebase" yaml:"rebase"` // Dumps stored as diffs before a full dump is stored again.
+}
+
+// InletConfig controls how background writes are batched.
+type InletConfig struct {
+	BatchSize int `mapstructure:"batch_size" yaml:"batch_size"` // Queries written per database round-trip.
+}
+
+// CORSConfig controls which browser origins may call the REST API. CORS
+// headers are only sent when at least one origin is allowed.
+type CORSConfig struct {
+	AllowedOrigins []string `mapstructure:"allowed_origins" yaml:"allowed_origins"` // Exact origins, or "*" for any.
+	AllowedHeaders []string `mapstructure:"allowed_headers" yaml:"allowed_headers"` // Request headers browsers may send.
+	MaxAge         int      `mapstructure:"max_age" yaml:"max_age"`                 // Seconds browsers may cache a preflight.
+}
+
+// RateLimitConfig limits how many requests each client may make.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second" yaml:"requests_per_second"` // 0 means unlimited.
+	Burst             int     `mapstructure:"burst" yaml:"burst"`                             // Requests allowed at once.
+}
+
+// defaults lists every setting with its default value. Only settings listed
+// here can be set through the environment.
+var defaults = map[string]interface{}{
+	"database":                       "",
+	"listen":                         ":10100",
+	"max_sync_lag":                   10,
+	"socket":                         "./internal.sock",
+	"prefetch_workers":               4,
+	"stream_buffer":                  1000,
+	"iterators":                      []string{"snapshots", "genesis", "webhooks"},
+	"state_dumps":                    []string{},
+	"snapshots.schedule":             "daily",
+	"snapshots.timezone":             "UTC",
+	"snapshots.blocks":               600,
+	"snapshots.catch_up":             100,
+	"genesis.schedule":               "daily",
+	"genesis.timezone":               "UTC",
+	"genesis.blocks":                 600,
+	"genesis.rebase":                 30,
+	"inlet.batch_size":               1,
+	"cors.allowed_origins":           []string{},
+	"cors.allowed_headers":           []string{"Content-Type"},
+	"cors.max_age":                   300,
+	"rate_limit.requests_per_second": 0.0,
+	"rate_limit.burst":               20,
+}
+
+// flagName converts a setting key to the name of its command line flag.
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// ConfigFlags registers a command line flag for every setting, along with
+// `--config` to choose the configuration file.
+func ConfigFlags(flags *pflag.FlagSet) {
+	flags.String("config", "", "configuration file, YAML or TOML (default ./hippias.yaml or ./hippias.toml if present)")
+	flags.String(flagName("database"), "", "Postgres connection URL")
+	flags.String(flagName("listen"), "", "address the REST server listens on")
+	flags.Int(flagName("max_sync_lag"), 0, "blocks the extractor may trail the node by and still be ready")
+	flags.String(flagName("socket"), "", "path to the oasis-node gRPC socket")
+	flags.Int(flagName("prefetch_workers"), 0, "heights fetched concurrently ahead of the extractor")
+	flags.Int(flagName("stream_buffer"), 0, "recent blocks streaming clients can resume from")
+	flags.StringSlice(flagName("iterators"), nil, "block iterators the extractor runs")
+	flags.StringSlice(flagName("state_dumps"), nil, "genesis or state dump files, or directories of them, to serve instead of a node")
+	flags.String(flagName("snapshots.schedule"), "", "when to snapshot accounts: daily, hourly, blocks, epoch or none")
+	flags.String(flagName("snapshots.timezone"), "", "timezone days start in for the daily snapshot schedule")
+	flags.Int(flagName("snapshots.blocks"), 0, "blocks between snapshots for the blocks snapshot schedule")
+	flags.Int(flagName("snapshots.catch_up"), 0, "most snapshots missed while stopped to take on restart, 0 means none")
+	flags.String(flagName("genesis.schedule"), "", "when to dump the staking state: daily, hourly, blocks, epoch or none")
+	flags.String(flagName("genesis.timezone"), "", "timezone days start in for the daily state dump schedule")
+	flags.Int(flagName("genesis.blocks"), 0, "blocks between state dumps for the blocks state dump schedule")
+	flags.Int(flagName("genesis.rebase"), 0, "state dumps stored as diffs before a full dump is stored again")
+	flags.Int(flagName("inlet.batch_size"), 0, "queries written per database round-trip by the Inlet")
+	flags.StringSlice(flagName("cors.allowed_origins"), nil, "origins allowed to call the REST API, * for any")
+	flags.StringSlice(flagName("cors.allowed_headers"), nil, "request headers browsers may send")
+	flags.Int(flagName("cors.max_age"), 0, "seconds browsers may cache a CORS preflight")
+	flags.Float64(flagName("rate_limit.requests_per_second"), 0, "requests per second allowed per client, 0 means unlimited")
+	flags.Int(flagName("rate_limit.burst"), 0, "requests a client may make at once")
+}
+
+// LoadConfig builds the configuration from defaults, the configuration file,
+// the environment, and any flags registered with ConfigFlags that were set.
+func LoadConfig(flags *pflag.FlagSet) (Config, error) {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	// Configuration File
+	path := os.Getenv("HIPPIAS_CONFIG")
+	if flag := flags.Lookup("config"); flag != nil && flag.Changed {
+		path = flag.Value.String()
+	}
+
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("hippias")
+		v.AddConfigPath(".")
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path != "" || !errors.As(err, &notFound) {
+			return Config{}, fmt.Errorf("LoadConfig: failed to read config file, %w", err)
+		}
+	}
+
+	// Environment
+	v.SetEnvPrefix("HIPPIAS")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	if err := v.BindEnv("database", "HIPPIAS_DB"); err != nil {
+		return Config{}, fmt.Errorf("LoadConfig: failed to bind environment, %w", err)
+	}
+
+	// HIPPIAS_PORT predates the listen address, and is still honoured when
+	// nothing more specific is set.
+	if port := os.Getenv("HIPPIAS_PORT"); port != "" && !v.InConfig("listen") && os.Getenv("HIPPIAS_LISTEN") == "" {
+		v.SetDefault("listen", ":"+port)
+	}
+
+	// Flags, which viper only applies when they were set explicitly.
+	for key := range defaults {
+		if flag := flags.Lookup(flagName(key)); flag != nil {
+			if err := v.BindPFlag(key, flag); err != nil {
+				return Config{}, fmt.Errorf("LoadConfig: failed to bind flag, %w", err)
+			}
+		}
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return Config{}, fmt.Errorf("LoadConfig: invalid configuration, %w", err)
+	}
+
+	return config, nil
+}
+
+// Validate checks every setting is usable, reporting all problems at once.
+func (config Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if config.DatabasePath == "" {
+		problem("database must be set")
+	} else if _, err := url.Parse(config.DatabasePath); err != nil {
+		problem("database is not a valid URL, %v", err)
+	}
+
+	if config.OasisSocket == "" {
+		problem("socket must be set")
+	}
+
+	if _, _, err := net.SplitHostPort(config.ListenAddress); err != nil {
+		problem("listen must be a host:port address, %v", err)
+	}
+
+	if config.MaxSyncLag < 0 {
+		problem("max_sync_lag must not be negative")
+	}
+
+	if config.PrefetchWorkers < 1 {
+		problem("prefetch_workers must be at least 1")
+	}
+
+	if config.StreamBuffer < 1 {
+		problem("stream_buffer must be at least 1")
+	}
+
+	if config.Snapshots.CatchUp < 0 {
+		problem("snapshots.catch_up must not be negative")
+	}
+
+	if config.Genesis.Rebase < 0 {
+		problem("genesis.rebase must not be negative")
+	}
+
+	if config.Inlet.BatchSize < 1 {
+		problem("inlet.batch_size must be at least 1")
+	}
+
+	for _, origin := range config.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if parsed, err := url.Parse(origin); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			problem("cors.allowed_origins entry %q must be * or a scheme://host origin", origin)
 		}
-		return def
 	}
 
-	return Config{
-		DailySnapshots:    true,
-		DatabasePath:      defaultEnv("HIPPIAS_DB", ""),
-		ListenPort:        defaultEnv("HIPPIAS_PORT", "10100"),
-		OasisSocket:       defaultEnv("HIPPIAS_SOCKET", "./internal.sock"),
-		SnapshotFrequency: 0,
+	if config.CORS.MaxAge < 0 {
+		problem("cors.max_age must not be negative")
 	}
+
+	if config.RateLimit.RequestsPerSecond < 0 {
+		problem("rate_limit.requests_per_second must not be negative")
+	}
+
+	if config.RateLimit.RequestsPerSecond > 0 && config.RateLimit.Burst < 1 {
+		problem("rate_limit.burst must be at least 1 when rate limiting")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// Redacted returns a copy of the configuration that is safe to print, with the
+// database password removed.
+func (config Config) Redacted() Config {
+	if parsed, err := url.Parse(config.DatabasePath); err == nil && parsed.User != nil {
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			parsed.User = url.UserPassword(parsed.User.Username(), "REDACTED")
+			config.DatabasePath = parsed.String()
+		}
+	}
+
+	return config
 }
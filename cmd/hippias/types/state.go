@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"io/ioutil"
 
+	"github.com/ChorusOne/Hippias/cmd/hippias/feed"
 	"github.com/ChorusOne/Hippias/pkg/oasis"
 	"github.com/gchaincl/dotsql"
 )
@@ -18,6 +19,10 @@ type State struct {
 	Api oasis.API
 	Db  *sql.DB
 	Dot *dotsql.DotSql
+
+	// Feed carries blocks to streaming clients as the extractor commits
+	// them, it is nil when nothing is streaming.
+	Feed *feed.Feed
 }
 
 func check(err error) {
@@ -45,5 +50,5 @@ func NewState(api oasis.API, db *sql.DB) State {
 		}
 	}
 
-	return State{api, db, dot}
+	return State{Api: api, Db: db, Dot: dot}
 }
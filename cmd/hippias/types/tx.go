@@ -0,0 +1,55 @@
+// Tx wraps a database transaction together with the named queries loaded into
+// State, so that components writing related data (such as everything extracted
+// from a single block) can commit it all at once or not at all.
+
+package types
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gchaincl/dotsql"
+)
+
+// Tx is a handle for running named queries inside one database transaction.
+type Tx struct {
+	tx  *sql.Tx
+	dot *dotsql.DotSql
+}
+
+// Begin starts a new database transaction on the shared database connection.
+// The transaction is rolled back if the context is cancelled before Commit.
+func (state State) Begin(ctx context.Context) (*Tx, error) {
+	tx, err := state.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{tx, state.Dot}, nil
+}
+
+// Exec runs a named query within the transaction.
+func (tx *Tx) Exec(name string, args ...interface{}) (sql.Result, error) {
+	return tx.dot.Exec(tx.tx, name, args...)
+}
+
+// Query runs a named query within the transaction.
+func (tx *Tx) Query(name string, args ...interface{}) (*sql.Rows, error) {
+	return tx.dot.Query(tx.tx, name, args...)
+}
+
+// QueryRow runs a named query expected to return at most one row within the
+// transaction.
+func (tx *Tx) QueryRow(name string, args ...interface{}) (*sql.Row, error) {
+	return tx.dot.QueryRow(tx.tx, name, args...)
+}
+
+// Commit persists every write made within the transaction.
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback discards every write made within the transaction.
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
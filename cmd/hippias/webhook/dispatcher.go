@@ -0,0 +1,280 @@
+// This file sends queued webhook deliveries. Deliveries are queued by the
+// extractor in the same database transaction as the block they come from, and
+// the dispatcher polls for due ones, so nothing is lost if the process stops
+// between a block being committed and its notifications being sent.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ChorusOne/Hippias/cmd/hippias/types"
+	"github.com/lib/pq"
+)
+
+// Delivery statuses recorded in the delivery log.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Failed deliveries are retried after retryMinDelay, doubling up to
+// retryMaxDelay, and given up on after maxAttempts.
+const (
+	maxAttempts   = 10
+	retryMinDelay = 30 * time.Second
+	retryMaxDelay = 2 * time.Hour
+)
+
+// Each batch takes up to batchSize deliveries, at most webhookBatchSize of them
+// for any one webhook. Webhooks are sent to concurrently by up to
+// dispatchWorkers workers, each sending one webhook's deliveries in order, so a
+// slow or unreachable endpoint only holds up its own deliveries.
+const (
+	pollInterval     = 2 * time.Second
+	batchSize        = 100
+	webhookBatchSize = 10
+	dispatchWorkers  = 8
+	deliveryTimeout  = 10 * time.Second
+)
+
+// ScanWebhooks reads the rows returned by the queryWebhooks query.
+func ScanWebhooks(rows *sql.Rows) ([]Webhook, error) {
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(
+			&webhook.ID,
+			&webhook.URL,
+			&webhook.Secret,
+			pq.Array(&webhook.Addresses),
+			pq.Array(&webhook.Kinds),
+			&webhook.Created,
+		); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// delivery is a queued payload waiting to be sent.
+type delivery struct {
+	id       int64
+	webhook  int64
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+}
+
+// Dispatch sends due deliveries until the context is cancelled.
+func Dispatch(ctx context.Context, state types.State) {
+	client := &http.Client{Timeout: deliveryTimeout}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back, so a backlog does not
+		// wait on the poll interval.
+		for {
+			sent, err := dispatchBatch(ctx, state, client)
+			if err != nil {
+				log.Printf("Webhooks: dispatch failed, %v", err)
+				break
+			}
+			if sent < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchBatch attempts one batch of due deliveries, returning how many were
+// attempted.
+func dispatchBatch(ctx context.Context, state types.State, client *http.Client) (int, error) {
+	rows, err := state.Dot.QueryContext(ctx, state.Db, "queryDueWebhookDeliveries", batchSize, webhookBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("dispatchBatch: query failed, %w", err)
+	}
+
+	var due []delivery
+	for rows.Next() {
+		var d delivery
+		if err := rows.Scan(&d.id, &d.webhook, &d.payload, &d.attempts, &d.url, &d.secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("dispatchBatch: scan failed, %w", err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("dispatchBatch: read failed, %w", err)
+	}
+
+	// Group the batch by webhook, keeping each webhook's deliveries in order.
+	var order []int64
+	byWebhook := map[int64][]delivery{}
+	for _, d := range due {
+		if _, ok := byWebhook[d.webhook]; !ok {
+			order = append(order, d.webhook)
+		}
+		byWebhook[d.webhook] = append(byWebhook[d.webhook], d)
+	}
+
+	webhooks := make(chan []delivery)
+	errs := make(chan error, dispatchWorkers)
+	var wg sync.WaitGroup
+	for i := 0; i < dispatchWorkers && i < len(order); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for deliveries := range webhooks {
+				if err := dispatchWebhook(ctx, state, client, deliveries); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	for _, webhook := range order {
+		webhooks <- byWebhook[webhook]
+	}
+	close(webhooks)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return 0, err
+	}
+
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	return len(due), nil
+}
+
+// dispatchWebhook sends the due deliveries of a single webhook in order. Once
+// one fails the rest are left for a later batch, as the endpoint is unlikely to
+// accept them either.
+func dispatchWebhook(ctx context.Context, state types.State, client *http.Client, deliveries []delivery) error {
+	for _, d := range deliveries {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		code, sendErr := send(ctx, client, d)
+		if err := record(ctx, state, d, code, sendErr); err != nil {
+			return err
+		}
+
+		if sendErr != nil {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// send posts a delivery, returning the response status if one was received.
+func send(ctx context.Context, client *http.Client, d delivery) (int, error) {
+	timestamp := time.Now().Unix()
+	request, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Hippias-Webhook")
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(d.id, 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(d.secret, timestamp, d.payload))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
+
+// record writes the outcome of an attempt to the delivery log, scheduling the
+// next attempt if the delivery failed and has attempts left.
+func record(ctx context.Context, state types.State, d delivery, code int, sendErr error) error {
+	attempts := d.attempts + 1
+	status := StatusDelivered
+	next := time.Now().UTC()
+
+	var responseCode, lastError, delivered interface{}
+	if code != 0 {
+		responseCode = code
+	}
+
+	if sendErr == nil {
+		delivered = next
+	} else {
+		lastError = sendErr.Error()
+		status = StatusPending
+		if attempts >= maxAttempts {
+			status = StatusFailed
+		}
+
+		delay := retryMinDelay
+		for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+			delay *= 2
+		}
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+		next = next.Add(delay)
+
+		log.Printf("Webhooks: delivery %d to webhook %d failed (attempt %d/%d), %v", d.id, d.webhook, attempts, maxAttempts, sendErr)
+	}
+
+	if _, err := state.Dot.ExecContext(ctx, state.Db, "updateWebhookDelivery",
+		d.id,
+		status,
+		attempts,
+		next,
+		responseCode,
+		lastError,
+		delivered,
+	); err != nil {
+		return fmt.Errorf("record: failed to update delivery %d, %w", d.id, err)
+	}
+
+	return nil
+}
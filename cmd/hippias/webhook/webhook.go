@@ -0,0 +1,153 @@
+// This file decides which staking events a webhook is notified about, and what
+// the notification looks like. Every notification concerns one address and one
+// kind of balance change, so a transfer between two watched addresses produces
+// a transfer_out for the sender and a transfer_in for the receiver.
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ChorusOne/Hippias/pkg/oasis"
+)
+
+// Event kinds a webhook can subscribe to.
+const (
+	KindTransferIn    = "transfer_in"
+	KindTransferOut   = "transfer_out"
+	KindEscrowAdd     = "escrow_add"
+	KindEscrowTake    = "escrow_take"
+	KindEscrowReclaim = "escrow_reclaim"
+	KindBurn          = "burn"
+)
+
+// Kinds lists every event kind, in the order they are documented.
+var Kinds = []string{
+	KindTransferIn,
+	KindTransferOut,
+	KindEscrowAdd,
+	KindEscrowTake,
+	KindEscrowReclaim,
+	KindBurn,
+}
+
+// Headers set on every delivery. The signature is a hex encoded HMAC-SHA256,
+// keyed by the webhook secret, over the timestamp header, a period, and the
+// request body. Receivers should reject old timestamps to prevent replays.
+const (
+	HeaderDelivery  = "X-Hippias-Delivery"
+	HeaderTimestamp = "X-Hippias-Timestamp"
+	HeaderSignature = "X-Hippias-Signature"
+)
+
+// Webhook is a registered callback.
+type Webhook struct {
+	ID        int64
+	URL       string
+	Secret    string
+	Addresses []string
+	Kinds     []string
+	Created   time.Time
+}
+
+// Payload is the JSON body posted to a webhook.
+type Payload struct {
+	Webhook    int64              `json:"webhook"`     // ID of the webhook notified.
+	Kind       string             `json:"kind"`        // One of the Kind constants.
+	Address    string             `json:"address"`     // Watched address the event affects.
+	Height     oasis.Height       `json:"height"`      // Height the event occurred at.
+	Date       time.Time          `json:"date"`        // Time of the block.
+	EventIndex int                `json:"event_index"` // Position of the event within the block.
+	Event      oasis.StakingEvent `json:"event"`       // The event itself.
+}
+
+// ValidKind checks whether kind is one of the supported event kinds.
+func ValidKind(kind string) bool {
+	for _, known := range Kinds {
+		if kind == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// involvement is an address affected by an event, and how.
+type involvement struct {
+	kind    string
+	address oasis.Address
+}
+
+// involvements lists every address an event affects.
+func involvements(event oasis.StakingEvent) []involvement {
+	switch {
+	case event.Transfer != nil:
+		return []involvement{
+			{KindTransferOut, event.Transfer.From},
+			{KindTransferIn, event.Transfer.To},
+		}
+
+	case event.Burn != nil:
+		return []involvement{{KindBurn, event.Burn.Owner}}
+
+	case event.Escrow != nil && event.Escrow.Add != nil:
+		return []involvement{
+			{KindEscrowAdd, event.Escrow.Add.Owner},
+			{KindEscrowAdd, event.Escrow.Add.Escrow},
+		}
+
+	case event.Escrow != nil && event.Escrow.Take != nil:
+		return []involvement{{KindEscrowTake, event.Escrow.Take.Owner}}
+
+	case event.Escrow != nil && event.Escrow.Reclaim != nil:
+		return []involvement{
+			{KindEscrowReclaim, event.Escrow.Reclaim.Owner},
+			{KindEscrowReclaim, event.Escrow.Reclaim.Escrow},
+		}
+	}
+
+	return nil
+}
+
+// Match returns a payload for every watched address and kind the event
+// affects. The caller fills in the block details.
+func (self Webhook) Match(event oasis.StakingEvent) []Payload {
+	var payloads []Payload
+	for _, involved := range involvements(event) {
+		if !contains(self.Kinds, involved.kind) || !contains(self.Addresses, involved.address.String()) {
+			continue
+		}
+
+		payloads = append(payloads, Payload{
+			Webhook: self.ID,
+			Kind:    involved.kind,
+			Address: involved.address.String(),
+			Event:   event,
+		})
+	}
+
+	return payloads
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign computes the signature header for a delivery body sent at a timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s.", strconv.FormatInt(timestamp, 10))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -5,12 +5,17 @@ go 1.15
 require (
 	github.com/gchaincl/dotsql v1.0.0
 	github.com/go-chi/chi v4.1.2+incompatible
-	github.com/go-chi/docgen v1.0.5
-	github.com/golang-migrate/migrate/v4 v4.11.0 // indirect
-	github.com/lib/pq v1.7.0 // indirect
+	github.com/golang-migrate/migrate/v4 v4.11.0
+	github.com/lib/pq v1.7.0
 	github.com/oasisprotocol/oasis-core/go v0.0.0-20200706191123-e5f879149d9a
+	github.com/prometheus/client_golang v1.5.1
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.5
+	github.com/spf13/viper v1.7.0
 	github.com/tendermint/tendermint v0.33.6
 	golang.org/dl v0.0.0-20200611200201-72429b14455f // indirect
+	golang.org/x/net v0.0.0-20200602114024-627f9648deb9
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	google.golang.org/grpc v1.30.0
+	gopkg.in/yaml.v2 v2.2.7
 )
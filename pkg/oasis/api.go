@@ -1,31 +1,64 @@
 // API is an interface that describes a method of interfacing with an Oasis
 // based blockchain. The details of how this works are abstracted away from the
-// consumer of this package. The current and only implementation can be found
-// in grpc.go
+// consumer of this package. The main implementation can be found in grpc.go
 
 package oasis
 
+import (
+	"context"
+	"errors"
+)
+
+// Errors that API implementations wrap, so callers can tell the cause of a
+// failure apart from a broken connection.
+var (
+	// ErrNotFound is returned when the requested item does not exist. This
+	// includes AtHeight asking for a height the API holds no state for.
+	ErrNotFound = errors.New("not found")
+
+	// ErrNotSynced is returned when the API is used before it has seen a
+	// block, and so has no state to answer from yet.
+	ErrNotSynced = errors.New("no block has been synced yet")
+)
+
 // API implements this packages API interface. All methods automatically return
 // data about the current synced blockchain height. See `AtHeight` which can be
 // used to get an instance fixed to a specific height.
+//
+// Every method that may talk to a node takes a context, and reports failures
+// as errors rather than exiting or returning empty results, so that callers
+// can decide whether to retry.
 type API interface {
 	// Utility Functions
-	AtHeight(Height) API
+	AtHeight(context.Context, Height) (API, error)
 	DecodeKey(string) (Address, error)
 
+	// Cheap lookups at a height, which unlike AtHeight do not fetch the
+	// state at that height.
+	BlockAt(context.Context, Height) (Block, error)
+	EpochAt(context.Context, Height) (Epoch, error)
+
 	// General Chain Information
-	Account(Address) (*Account, error)
-	AccountDelegations(Address) []Delegation
-	Accounts() []Address
-	Delegations() []Delegation
-	GetBlock() (Block, Height)
-	GetEvents() []StakingEvent
-	GetGenesisState() (*Genesis, error)
-	GetTransactions() []Transaction
-	GetValidatorCommission(Address) (*Amount, *Amount, error)
-	Pool() (*Pool, error)
-
-	// Create Live Subscriptions to Blockchain Data
-	WatchBlocks() (chan Block, error)
-	WatchStakingEvents() (chan StakingEvent, error)
+	Account(context.Context, Address) (*Account, error)
+	AccountDelegations(context.Context, Address) ([]Delegation, error)
+	Accounts(context.Context) ([]Address, error)
+	Delegations(context.Context) ([]Delegation, error)
+	GetBlock(context.Context) (Block, error)
+	GetEpoch(context.Context) (Epoch, error)
+	GetEvents(context.Context) ([]StakingEvent, error)
+	GetGenesisState(context.Context) (*Genesis, error)
+	GetTransactions(context.Context) ([]Transaction, error)
+	GetValidatorCommission(context.Context, Address) (*Amount, *Amount, error)
+	Pool(context.Context) (*Pool, error)
+
+	// Create Live Subscriptions to Blockchain Data. Channels are closed when
+	// the context is cancelled or the subscription ends.
+	WatchBlocks(context.Context) (<-chan Block, error)
+	WatchStakingEvents(context.Context) (<-chan StakingEvent, error)
+}
+
+// Connector is implemented by APIs that talk to a node over a connection, so
+// health checks can tell whether it is up without making a request.
+type Connector interface {
+	Connected() bool
 }
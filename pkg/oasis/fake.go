@@ -0,0 +1,349 @@
+// This file implements this packages API interface backed entirely by scripted
+// in-memory fixtures. It exists so that consumers of the API (the extractor,
+// block iterators and REST endpoints) can be driven deterministically without
+// a running oasis-node.
+//
+// A Fake is built from a sequence of FakeBlocks, each describing the complete
+// chain state at one height. Pushing a new FakeBlock advances the tip and
+// delivers the block and its events to anyone watching.
+
+package oasis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	epochtime "github.com/oasisprotocol/oasis-core/go/epochtime/api"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+)
+
+// Types
+// ------------------------------------------------------------------------------
+
+// FakeBlock is a fixture describing everything the API can report about the
+// chain at a single height. The staking State carries the ledger, delegations
+// and commission schedules, commission rates are resolved against Epoch.
+type FakeBlock struct {
+	Block        Block
+	Epoch        epochtime.EpochTime
+	State        staking.Genesis
+	Events       []StakingEvent
+	Transactions []Transaction
+}
+
+// Fake serves API requests from a set of FakeBlock fixtures. A Fake returned by
+// NewFake follows the tip, while one returned by AtHeight is fixed in place.
+type Fake struct {
+	chain  *fakeChain // Fixtures shared between all views of the same chain.
+	height Height     // Fixed height of this view, 0 means follow the tip.
+}
+
+// Enforce Interface
+var _ API = &Fake{}
+
+// fakeChain stores the fixtures and subscribers shared by a Fake and every
+// view derived from it with AtHeight.
+type fakeChain struct {
+	mu            sync.RWMutex
+	delivery      sync.Mutex // Held while delivering, so watchers are never closed mid-send.
+	blocks        map[Height]*FakeBlock
+	tip           Height
+	blockWatchers []*fakeBlockWatcher
+	eventWatchers []*fakeEventWatcher
+}
+
+// fakeBlockWatcher is a single WatchBlocks subscription.
+type fakeBlockWatcher struct {
+	ctx     context.Context
+	channel chan Block
+}
+
+// fakeEventWatcher is a single WatchStakingEvents subscription.
+type fakeEventWatcher struct {
+	ctx     context.Context
+	channel chan StakingEvent
+}
+
+// API implementation for Fake
+// ------------------------------------------------------------------------------
+
+// NewFake creates a Fake pre-loaded with the given fixtures. The tip is set to
+// the highest height provided. No watchers exist yet, so nothing is delivered.
+func NewFake(blocks ...FakeBlock) *Fake {
+	chain := &fakeChain{blocks: make(map[Height]*FakeBlock)}
+	for i := range blocks {
+		chain.store(blocks[i])
+	}
+
+	return &Fake{chain: chain}
+}
+
+// Push records a new fixture, advances the tip to it if it is higher than the
+// current tip, and then delivers the block followed by its events to every
+// watcher. Delivery is synchronous so that watchers observe fixtures in the
+// exact order they were pushed, watchers whose context is cancelled are
+// skipped.
+func (fake *Fake) Push(block FakeBlock) {
+	fake.chain.delivery.Lock()
+	defer fake.chain.delivery.Unlock()
+
+	fake.chain.mu.Lock()
+	fake.chain.store(block)
+	blockWatchers := append([]*fakeBlockWatcher{}, fake.chain.blockWatchers...)
+	eventWatchers := append([]*fakeEventWatcher{}, fake.chain.eventWatchers...)
+	fake.chain.mu.Unlock()
+
+	for _, watcher := range blockWatchers {
+		select {
+		case watcher.channel <- block.Block:
+		case <-watcher.ctx.Done():
+		}
+	}
+
+	for _, watcher := range eventWatchers {
+		for _, event := range block.Events {
+			select {
+			case watcher.channel <- event:
+			case <-watcher.ctx.Done():
+			}
+		}
+	}
+}
+
+// store writes a fixture into the chain, the caller must hold the lock if the
+// chain is already shared.
+func (chain *fakeChain) store(block FakeBlock) {
+	chain.blocks[block.Block.Height] = &block
+	if block.Block.Height > chain.tip {
+		chain.tip = block.Block.Height
+	}
+}
+
+// fixture resolves the FakeBlock this view answers from.
+func (fake *Fake) fixture() (*FakeBlock, error) {
+	fake.chain.mu.RLock()
+	defer fake.chain.mu.RUnlock()
+
+	height := fake.height
+	if height == 0 {
+		height = fake.chain.tip
+	}
+
+	block, ok := fake.chain.blocks[height]
+	if !ok {
+		return nil, fmt.Errorf("No Fixture for Height: %d, %w", height, ErrNotFound)
+	}
+
+	return block, nil
+}
+
+// Utilities
+// -----------------------------------------------------------------------------
+
+// AtHeight fixes a view of the chain at a height, it fails if no fixture
+// exists for that height.
+func (fake *Fake) AtHeight(ctx context.Context, height Height) (API, error) {
+	view := &Fake{
+		chain:  fake.chain,
+		height: height,
+	}
+
+	if _, err := view.fixture(); err != nil {
+		return nil, fmt.Errorf("AtHeight: %w", err)
+	}
+
+	return view, nil
+}
+
+// BlockAt returns the block of the fixture at a height.
+func (fake *Fake) BlockAt(ctx context.Context, height Height) (Block, error) {
+	view := &Fake{chain: fake.chain, height: height}
+	return view.GetBlock(ctx)
+}
+
+// EpochAt returns the epoch of the fixture at a height.
+func (fake *Fake) EpochAt(ctx context.Context, height Height) (Epoch, error) {
+	view := &Fake{chain: fake.chain, height: height}
+	return view.GetEpoch(ctx)
+}
+
+// DecodeKey is a small helper to decode Oasis' internal encoded keys to
+// something we can work with locally.
+func (fake *Fake) DecodeKey(id string) (Address, error) {
+	var address Address
+	err := address.UnmarshalText([]byte(id))
+	return address, err
+}
+
+// API
+// -----------------------------------------------------------------------------
+
+func (fake *Fake) Account(ctx context.Context, id Address) (*Account, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerAccount(&block.State, block.Block.Height, id)
+}
+
+func (fake *Fake) AccountDelegations(ctx context.Context, id Address) ([]Delegation, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerAccountDelegations(&block.State, id), nil
+}
+
+func (fake *Fake) Accounts(ctx context.Context) ([]Address, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerAddresses(&block.State), nil
+}
+
+func (fake *Fake) Delegations(ctx context.Context) ([]Delegation, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerDelegations(&block.State), nil
+}
+
+func (fake *Fake) GetBlock(ctx context.Context) (Block, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return Block{}, err
+	}
+
+	return block.Block, nil
+}
+
+func (fake *Fake) GetEpoch(ctx context.Context) (Epoch, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return 0, err
+	}
+
+	return block.Epoch, nil
+}
+
+func (fake *Fake) GetEvents(ctx context.Context) ([]StakingEvent, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Events, nil
+}
+
+// GetGenesisState encodes the fixtures staking state in the same JSON format
+// the gRPC implementation produces.
+func (fake *Fake) GetGenesisState(ctx context.Context) (*Genesis, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(&block.State)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode Genesis for height %v: %w", block.Block.Height, err)
+	}
+
+	return &Genesis{encoded}, nil
+}
+
+func (fake *Fake) GetTransactions(ctx context.Context) ([]Transaction, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Transactions, nil
+}
+
+func (fake *Fake) GetValidatorCommission(ctx context.Context, id Address) (*Amount, *Amount, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ledgerCommission(&block.State, block.Epoch, id)
+}
+
+func (fake *Fake) Pool(ctx context.Context) (*Pool, error) {
+	block, err := fake.fixture()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := block.State.CommonPool.Clone()
+	return pool, nil
+}
+
+// Watchers
+// -----------------------------------------------------------------------------
+
+// WatchBlocks returns a channel that receives every block pushed from now on.
+// The channel is closed once the context is cancelled.
+func (fake *Fake) WatchBlocks(ctx context.Context) (<-chan Block, error) {
+	watcher := &fakeBlockWatcher{ctx, make(chan Block)}
+
+	fake.chain.mu.Lock()
+	fake.chain.blockWatchers = append(fake.chain.blockWatchers, watcher)
+	fake.chain.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		fake.chain.delivery.Lock()
+		defer fake.chain.delivery.Unlock()
+		fake.chain.mu.Lock()
+		defer fake.chain.mu.Unlock()
+
+		for i, other := range fake.chain.blockWatchers {
+			if other == watcher {
+				fake.chain.blockWatchers = append(fake.chain.blockWatchers[:i], fake.chain.blockWatchers[i+1:]...)
+				break
+			}
+		}
+
+		close(watcher.channel)
+	}()
+
+	return watcher.channel, nil
+}
+
+// WatchStakingEvents returns a channel that receives the events of every block
+// pushed from now on. The channel is closed once the context is cancelled.
+func (fake *Fake) WatchStakingEvents(ctx context.Context) (<-chan StakingEvent, error) {
+	watcher := &fakeEventWatcher{ctx, make(chan StakingEvent)}
+
+	fake.chain.mu.Lock()
+	fake.chain.eventWatchers = append(fake.chain.eventWatchers, watcher)
+	fake.chain.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		fake.chain.delivery.Lock()
+		defer fake.chain.delivery.Unlock()
+		fake.chain.mu.Lock()
+		defer fake.chain.mu.Unlock()
+
+		for i, other := range fake.chain.eventWatchers {
+			if other == watcher {
+				fake.chain.eventWatchers = append(fake.chain.eventWatchers[:i], fake.chain.eventWatchers[i+1:]...)
+				break
+			}
+		}
+
+		close(watcher.channel)
+	}()
+
+	return watcher.channel, nil
+}
@@ -0,0 +1,398 @@
+// This file implements this packages API interface over state dumps stored on
+// disk, so that historical network states (such as chains from before an
+// upgrade) can be served without a node. Two kinds of JSON document are read:
+//
+//   - Genesis documents, as exported by `oasis-node debug dumpdb` or used to
+//     start a network, which carry their height, time and epoch.
+//   - Staking states, as returned by GetGenesisState, which carry neither. The
+//     height is taken from the last number in their file name instead, such
+//     as 1500000 for `staking-1500000.json`.
+//
+// Reads at a height are answered from the dump at or below it. State dumps do
+// not hold blocks, so there are no transactions or events to report.
+
+package oasis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+)
+
+// Types
+// ------------------------------------------------------------------------------
+
+// File serves API requests from state dumps on disk. A File returned by NewFile
+// answers from the most recent dump, while one returned by AtHeight is fixed
+// to the dump at or below that height.
+type File struct {
+	dumps *fileDumps // Dumps shared between all views of the same files.
+	dump  *fileDump  // Dump this view answers from.
+}
+
+// Enforce Interface
+var _ API = &File{}
+
+// fileDump describes a single state dump, without its staking state.
+type fileDump struct {
+	path     string
+	block    Block
+	epoch    Epoch
+	hasEpoch bool // Staking states do not record the epoch they were taken at.
+}
+
+// fileDumps holds every known dump in height order. Staking states are large,
+// so only the most recently used one is kept in memory.
+type fileDumps struct {
+	mu     sync.Mutex
+	dumps  []*fileDump
+	cached *fileDump
+	state  *staking.Genesis
+}
+
+// fileDocument is the part of a dump needed to tell what kind it is, and to
+// describe it. Staking is only set for genesis documents.
+type fileDocument struct {
+	Height    *Height   `json:"height"`
+	Time      time.Time `json:"genesis_time"`
+	ChainID   string    `json:"chain_id"`
+	EpochTime struct {
+		Base Epoch `json:"base"`
+	} `json:"epochtime"`
+	Staking *staking.Genesis `json:"staking"`
+}
+
+// fileHeightPattern finds the numbers in a file name.
+var fileHeightPattern = regexp.MustCompile(`[0-9]+`)
+
+// API implementation for File
+// ------------------------------------------------------------------------------
+
+// NewFile indexes the state dumps at the given paths, each of which may be a
+// JSON file or a directory of them. Every dump is read once to find its height,
+// and two dumps may not share a height.
+func NewFile(paths ...string) (*File, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("NewFile: %w", err)
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("NewFile: %w", err)
+		}
+		files = append(files, matches...)
+	}
+
+	dumps := &fileDumps{}
+	for _, path := range files {
+		dump, _, err := readDump(path)
+		if err != nil {
+			return nil, fmt.Errorf("NewFile: %w", err)
+		}
+		dumps.dumps = append(dumps.dumps, dump)
+	}
+
+	if len(dumps.dumps) == 0 {
+		return nil, fmt.Errorf("NewFile: no state dumps found in %v", paths)
+	}
+
+	sort.Slice(dumps.dumps, func(i, j int) bool {
+		return dumps.dumps[i].block.Height < dumps.dumps[j].block.Height
+	})
+
+	for i := 1; i < len(dumps.dumps); i++ {
+		if dumps.dumps[i].block.Height == dumps.dumps[i-1].block.Height {
+			return nil, fmt.Errorf("NewFile: %s and %s are both at height %d",
+				dumps.dumps[i-1].path,
+				dumps.dumps[i].path,
+				dumps.dumps[i].block.Height,
+			)
+		}
+	}
+
+	return &File{
+		dumps: dumps,
+		dump:  dumps.dumps[len(dumps.dumps)-1],
+	}, nil
+}
+
+// readDump decodes a state dump, describing it and returning its staking state.
+func readDump(path string) (*fileDump, *staking.Genesis, error) {
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var document fileDocument
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		return nil, nil, fmt.Errorf("Failed to decode %s, %w", path, err)
+	}
+
+	// Genesis documents describe themselves.
+	if document.Staking != nil {
+		if document.Height == nil {
+			return nil, nil, fmt.Errorf("Genesis document %s has no height", path)
+		}
+
+		return &fileDump{
+			path: path,
+			block: Block{
+				ChainID: document.ChainID,
+				Height:  *document.Height,
+				Time:    document.Time,
+			},
+			epoch:    document.EpochTime.Base,
+			hasEpoch: true,
+		}, document.Staking, nil
+	}
+
+	// Anything else is a staking state, named after its height.
+	numbers := fileHeightPattern.FindAllString(filepath.Base(path), -1)
+	if len(numbers) == 0 {
+		return nil, nil, fmt.Errorf("Staking state %s has no height in its file name", path)
+	}
+
+	height, err := strconv.ParseInt(numbers[len(numbers)-1], 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Staking state %s has an invalid height in its file name, %w", path, err)
+	}
+
+	var state staking.Genesis
+	if err := json.Unmarshal(encoded, &state); err != nil {
+		return nil, nil, fmt.Errorf("Failed to decode %s, %w", path, err)
+	}
+
+	return &fileDump{
+		path:  path,
+		block: Block{Height: height},
+	}, &state, nil
+}
+
+// load returns the staking state of a dump, reading it from disk unless it was
+// the last one used.
+func (dumps *fileDumps) load(dump *fileDump) (*staking.Genesis, error) {
+	dumps.mu.Lock()
+	defer dumps.mu.Unlock()
+
+	if dumps.cached == dump {
+		return dumps.state, nil
+	}
+
+	_, state, err := readDump(dump.path)
+	if err != nil {
+		return nil, err
+	}
+
+	dumps.cached, dumps.state = dump, state
+	return state, nil
+}
+
+// state returns the staking state this view answers from.
+func (file *File) state() (*staking.Genesis, error) {
+	return file.dumps.load(file.dump)
+}
+
+// Utilities
+// -----------------------------------------------------------------------------
+
+// AtHeight fixes a view to the dump at or below a height, it fails if every
+// dump is more recent than that.
+func (file *File) AtHeight(ctx context.Context, height Height) (API, error) {
+	dumps := file.dumps.dumps
+	index := sort.Search(len(dumps), func(i int) bool {
+		return dumps[i].block.Height > height
+	})
+
+	if index == 0 {
+		return nil, fmt.Errorf("AtHeight: no state dump at or below %d, %w", height, ErrNotFound)
+	}
+
+	return &File{
+		dumps: file.dumps,
+		dump:  dumps[index-1],
+	}, nil
+}
+
+// BlockAt describes the dump taken at a height, state dumps only know the
+// blocks they were taken at.
+func (file *File) BlockAt(ctx context.Context, height Height) (Block, error) {
+	view, err := file.AtHeight(ctx, height)
+	if err != nil {
+		return Block{}, err
+	}
+
+	block, _ := view.GetBlock(ctx)
+	if block.Height != height {
+		return Block{}, fmt.Errorf("BlockAt: no state dump at %d, %w", height, ErrNotFound)
+	}
+
+	return block, nil
+}
+
+// EpochAt returns the epoch of the dump at or below a height.
+func (file *File) EpochAt(ctx context.Context, height Height) (Epoch, error) {
+	view, err := file.AtHeight(ctx, height)
+	if err != nil {
+		return 0, err
+	}
+
+	return view.GetEpoch(ctx)
+}
+
+// DecodeKey is a small helper to decode Oasis' internal encoded keys to
+// something we can work with locally.
+func (file *File) DecodeKey(id string) (Address, error) {
+	var address Address
+	err := address.UnmarshalText([]byte(id))
+	return address, err
+}
+
+// API
+// -----------------------------------------------------------------------------
+
+func (file *File) Account(ctx context.Context, id Address) (*Account, error) {
+	state, err := file.state()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerAccount(state, file.dump.block.Height, id)
+}
+
+func (file *File) AccountDelegations(ctx context.Context, id Address) ([]Delegation, error) {
+	state, err := file.state()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerAccountDelegations(state, id), nil
+}
+
+func (file *File) Accounts(ctx context.Context) ([]Address, error) {
+	state, err := file.state()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerAddresses(state), nil
+}
+
+func (file *File) Delegations(ctx context.Context) ([]Delegation, error) {
+	state, err := file.state()
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerDelegations(state), nil
+}
+
+// GetBlock describes the dump, staking states only know their height.
+func (file *File) GetBlock(ctx context.Context) (Block, error) {
+	return file.dump.block, nil
+}
+
+func (file *File) GetEpoch(ctx context.Context) (Epoch, error) {
+	if !file.dump.hasEpoch {
+		return 0, fmt.Errorf("GetEpoch: %s does not record its epoch, %w", file.dump.path, ErrNotFound)
+	}
+
+	return file.dump.epoch, nil
+}
+
+func (file *File) GetEvents(ctx context.Context) ([]StakingEvent, error) {
+	return []StakingEvent{}, nil
+}
+
+// GetGenesisState encodes the dumps staking state in the same JSON format the
+// gRPC implementation produces.
+func (file *File) GetGenesisState(ctx context.Context) (*Genesis, error) {
+	state, err := file.state()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode Genesis for height %v: %w", file.dump.block.Height, err)
+	}
+
+	return &Genesis{encoded}, nil
+}
+
+func (file *File) GetTransactions(ctx context.Context) ([]Transaction, error) {
+	return []Transaction{}, nil
+}
+
+// GetValidatorCommission resolves the commission rate at the dumps epoch. When
+// the epoch is unknown the first rate of the schedule is used, which is the one
+// in effect as nodes drop rates from the schedule once they are superseded.
+func (file *File) GetValidatorCommission(ctx context.Context, id Address) (*Amount, *Amount, error) {
+	state, err := file.state()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	epoch := file.dump.epoch
+	if account, ok := state.Ledger[id]; ok && !file.dump.hasEpoch {
+		if rates := account.Escrow.CommissionSchedule.Rates; len(rates) > 0 {
+			epoch = rates[0].Start
+		}
+	}
+
+	return ledgerCommission(state, epoch, id)
+}
+
+func (file *File) Pool(ctx context.Context) (*Pool, error) {
+	state, err := file.state()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := state.CommonPool.Clone()
+	return pool, nil
+}
+
+// Watchers
+// -----------------------------------------------------------------------------
+
+// WatchBlocks returns a channel that never receives a block, as state dumps
+// never change. The channel is closed once the context is cancelled.
+func (file *File) WatchBlocks(ctx context.Context) (<-chan Block, error) {
+	channel := make(chan Block)
+	go func() {
+		<-ctx.Done()
+		close(channel)
+	}()
+
+	return channel, nil
+}
+
+// WatchStakingEvents returns a channel that never receives an event, as state
+// dumps never change. The channel is closed once the context is cancelled.
+func (file *File) WatchStakingEvents(ctx context.Context) (<-chan StakingEvent, error) {
+	channel := make(chan StakingEvent)
+	go func() {
+		<-ctx.Done()
+		close(channel)
+	}()
+
+	return channel, nil
+}
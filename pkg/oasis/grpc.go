@@ -11,22 +11,31 @@ package oasis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/oasisprotocol/oasis-core/go/common/cbor"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-core/go/common/entity"
+	"github.com/oasisprotocol/oasis-core/go/common/node"
 	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
 
 	grpcOasis "github.com/oasisprotocol/oasis-core/go/common/grpc"
 	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	registry "github.com/oasisprotocol/oasis-core/go/registry/api"
 	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+	nodedb "github.com/oasisprotocol/oasis-core/go/storage/mkvs/db/api"
 	tmtypes "github.com/tendermint/tendermint/types"
 )
 
@@ -42,6 +51,7 @@ type Oasis struct {
 
 // Enforce Interface
 var _ API = &Oasis{}
+var _ Connector = &Oasis{}
 
 // chainState stores all the data that changes as the extractor sync with the
 // oasis chain. It's a separate struct from `Oasis` so that we can swap the
@@ -49,33 +59,102 @@ var _ API = &Oasis{}
 type chainState struct {
 	Block    *Block
 	Height   Height
-	Snapshot *staking.Genesis
+	Snapshot *staking.Genesis // Nil for views fixed by AtHeight until needed, see ledger.
+
+	load    sync.Once // Dumps Snapshot the first time it is needed.
+	loadErr error     // Why the dump failed, if it did.
 }
 
 // Utility Functions
 // ------------------------------------------------------------------------------
 
-func decodeBlockAsTendermint(block *consensus.Block) Block {
+func decodeBlockAsTendermint(block *consensus.Block) (Block, error) {
 	var tendermintBlock tmtypes.Block
 	if err := cbor.Unmarshal(block.Meta, &tendermintBlock); err != nil {
-		log.Fatalln("Failed to decode Tendermint Metadata")
+		return Block{}, fmt.Errorf("Failed to decode Tendermint Metadata, %w", err)
 	}
 
 	return Block{
 		Height:  block.Height,
 		ChainID: tendermintBlock.Header.ChainID,
 		Time:    tendermintBlock.Header.Time,
+	}, nil
+}
+
+// heightUnavailable marks failures caused by the node not having the state of a
+// height, such as heights pruned away or not reached yet, with ErrNotFound. This
+// tells them apart from the node itself being unreachable.
+func heightUnavailable(err error) error {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	switch {
+	case errors.Is(err, consensus.ErrVersionNotFound),
+		errors.Is(err, nodedb.ErrVersionNotFound),
+		errors.As(err, &grpcErr) && grpcErr.GRPCStatus().Code() == codes.NotFound:
+		return fmt.Errorf("%v, %w", err, ErrNotFound)
+	}
+
+	return err
+}
+
+// methodGetTransactionsWithResults is only served by oasis-node releases newer
+// than the oasis-core client library used here, so it is invoked by name.
+var methodGetTransactionsWithResults = grpcOasis.NewServiceName("Consensus").NewMethod("GetTransactionsWithResults", int64(0))
+
+// transactionsWithResults mirrors the response of GetTransactionsWithResults,
+// where each result belongs to the transaction at the same index.
+type transactionsWithResults struct {
+	Transactions [][]byte            `json:"transactions"`
+	Results      []transactionResult `json:"results"`
+}
+
+type transactionResult struct {
+	Error struct {
+		Module  string `json:"module,omitempty"`
+		Code    uint32 `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	} `json:"error"`
+	Events []struct {
+		Staking *staking.Event `json:"staking,omitempty"`
+	} `json:"events"`
+	GasUsed uint64 `json:"gas_used,omitempty"`
+}
+
+// fetchTransactions returns the raw transactions at a height along with their
+// results. Results are nil if the node is too old to report them.
+func (oasis *Oasis) fetchTransactions(ctx context.Context, height Height) ([][]byte, []transactionResult, error) {
+	var raw cbor.RawMessage
+	err := oasis.conn.Invoke(ctx, methodGetTransactionsWithResults.FullName(), height, &raw)
+	switch {
+	case err == nil:
+		// Decode leniently, so event kinds we don't know about don't fail
+		// the whole block.
+		var rsp transactionsWithResults
+		if err := cbor.UnmarshalTrusted(raw, &rsp); err != nil {
+			return nil, nil, fmt.Errorf("fetchTransactions: Unmarshal results failed, %w", err)
+		}
+
+		if len(rsp.Results) != len(rsp.Transactions) {
+			return nil, nil, fmt.Errorf("fetchTransactions: got %d results for %d txs", len(rsp.Results), len(rsp.Transactions))
+		}
+
+		return rsp.Transactions, rsp.Results, nil
+
+	case status.Code(err) == codes.Unimplemented:
+		api := consensus.NewConsensusClient(oasis.conn)
+		txs, err := api.GetTransactions(ctx, height)
+		return txs, nil, err
+
+	default:
+		return nil, nil, err
 	}
 }
 
 // syncChain attempts to copy current chain state into the local state.
-func (oasis *Oasis) syncChain(block *Block) error {
-	ctx := context.Background()
+func (oasis *Oasis) syncChain(ctx context.Context, block *Block) error {
 	api := staking.NewStakingClient(oasis.conn)
 	gen, err := api.StateToGenesis(ctx, block.Height)
 	if err != nil {
-		log.Fatalf("syncChain: Failed to sync, %v\n", err)
-		return err
+		return fmt.Errorf("syncChain: Failed to sync, %w", err)
 	}
 
 	// Update New State Atomically
@@ -90,14 +169,43 @@ func (oasis *Oasis) syncChain(block *Block) error {
 	return nil
 }
 
+// ledger returns the full staking state at the height of the view. The state
+// kept in sync with the chain already holds it, but views fixed by AtHeight
+// only dump it from the node the first time a method needs the whole ledger,
+// as single account reads are answered with queries at the height instead.
+func (oasis *Oasis) ledger(ctx context.Context) (*staking.Genesis, error) {
+	state := oasis.State
+	state.load.Do(func() {
+		if state.Snapshot != nil {
+			return
+		}
+
+		api := staking.NewStakingClient(oasis.conn)
+		snapshot, err := api.StateToGenesis(ctx, state.Height)
+		if err != nil {
+			state.loadErr = fmt.Errorf("ledger: Failed to dump state at %d, %w", state.Height, heightUnavailable(err))
+			return
+		}
+
+		state.Snapshot = snapshot
+	})
+
+	return state.Snapshot, state.loadErr
+}
+
 // freezeChain will atomically clone the current state and fix it at the
 // current height.
-func (oasis *Oasis) freezeChain() *Oasis {
+func (oasis *Oasis) freezeChain() (*Oasis, error) {
 	currentState := (*unsafe.Pointer)(unsafe.Pointer(&oasis.State))
+	state := (*chainState)(atomic.LoadPointer(currentState))
+	if state == nil {
+		return nil, ErrNotSynced
+	}
+
 	return &Oasis{
 		conn:  oasis.conn,
-		State: (*chainState)(atomic.LoadPointer(currentState)),
-	}
+		State: state,
+	}, nil
 }
 
 // API implementation for Oasis
@@ -105,15 +213,19 @@ func (oasis *Oasis) freezeChain() *Oasis {
 
 // NewOasis tries to open a gRPC connection with an existing oasis-core unix
 // socket. If it finds one, it spawns a goroutine that keeps track of the
-// current height of the chain.
-func NewOasis(address string) (*Oasis, error) {
+// current height of the chain until the context is cancelled.
+func NewOasis(ctx context.Context, address string) (*Oasis, error) {
 	// If the argument is a file, assume It's a UNIX socket.
 	if _, err := os.Stat(address); err == nil {
 		address = "unix:" + address
 	}
 
 	// Dial RPC, Insecure By Default
-	conn, err := grpcOasis.Dial(address, grpc.WithInsecure())
+	conn, err := grpcOasis.Dial(address,
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(unaryMetrics),
+		grpc.WithChainStreamInterceptor(streamMetrics),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -125,27 +237,39 @@ func NewOasis(address string) (*Oasis, error) {
 	// In order to allow the API to behave as if it is always currently
 	// querying the top block, we'll sync the object in the background with the
 	// tip of the chain.
-	waitChan := make(chan int)
-	waitOnce := sync.Once{}
+	channel, err := oasis.WatchBlocks(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("NewOasis: Could not open WatchBlocks channel, %w", err)
+	}
+
+	waitChan := make(chan struct{})
 	go func() {
-		if channel, err := oasis.WatchBlocks(); err == nil {
-			for {
-				select {
-				case block := <-channel:
-					oasis.syncChain(&block)
-					waitOnce.Do(func() { waitChan <- 0 })
-				}
+		synced := false
+		for block := range channel {
+			block := block
+			metricTipHeight.Set(float64(block.Height))
+			if err := oasis.syncChain(ctx, &block); err != nil {
+				log.Printf("NewOasis: %v\n", err)
+				continue
+			}
+
+			if !synced {
+				synced = true
+				close(waitChan)
 			}
 		}
 
-		log.Fatalln("NewOasis: Could not open WatchBlocks channel")
+		log.Println("NewOasis: WatchBlocks channel closed")
 	}()
 
 	// Wait for at least one block to sync before we allow the API to be
 	// considered ready.
 	select {
-	case _ = <-waitChan:
-		close(waitChan)
+	case <-waitChan:
+	case <-ctx.Done():
+		conn.Close()
+		return nil, ctx.Err()
 	}
 
 	return oasis, nil
@@ -154,23 +278,60 @@ func NewOasis(address string) (*Oasis, error) {
 // Utilities
 // -----------------------------------------------------------------------------
 
-func (oasis *Oasis) AtHeight(height Height) API {
-	ctx := context.Background()
+// Connected reports whether the gRPC connection to the node is established.
+func (oasis *Oasis) Connected() bool {
+	return oasis.conn.GetState() == connectivity.Ready
+}
+
+// AtHeight fixes a view to the state at a height. Heights the node has pruned
+// or not reached yet fail with ErrNotFound. The full staking state is only
+// dumped once a method needs the whole ledger, so reading a single account at
+// a height stays cheap.
+func (oasis *Oasis) AtHeight(ctx context.Context, height Height) (API, error) {
+	tendermintBlock, err := oasis.BlockAt(ctx, height)
+	if err != nil {
+		return nil, fmt.Errorf("AtHeight: %w", err)
+	}
+
+	return &Oasis{
+		conn: oasis.conn,
+		State: &chainState{
+			Block:  &tendermintBlock,
+			Height: tendermintBlock.Height,
+		},
+	}, nil
+}
+
+// BlockAt fetches the block at a height, without the state dump AtHeight takes.
+func (oasis *Oasis) BlockAt(ctx context.Context, height Height) (Block, error) {
 	api := consensus.NewConsensusClient(oasis.conn)
 	block, err := api.GetBlock(ctx, height)
 	if err != nil {
-		log.Fatalf("Attempted to process non-existing Block, %v\n", err)
-		return nil
+		return Block{}, fmt.Errorf("BlockAt: Failed to fetch Block %d, %w", height, heightUnavailable(err))
+	}
+
+	tendermintBlock, err := decodeBlockAsTendermint(block)
+	if err != nil {
+		return Block{}, fmt.Errorf("BlockAt: %w", err)
+	}
+
+	return tendermintBlock, nil
+}
+
+// EpochAt fetches the epoch at a height, without the state dump AtHeight takes.
+func (oasis *Oasis) EpochAt(ctx context.Context, height Height) (Epoch, error) {
+	api := consensus.NewConsensusClient(oasis.conn)
+	epoch, err := api.GetEpoch(ctx, height)
+	if err != nil {
+		return 0, fmt.Errorf("EpochAt: failed to fetch epoch at %d, %w", height, heightUnavailable(err))
 	}
 
-	tendermintBlock := decodeBlockAsTendermint(block)
-	newAPI := Oasis{conn: oasis.conn}
-	newAPI.syncChain(&tendermintBlock)
-	return oasis
+	return epoch, nil
 }
 
 // DecodeKey is a small helper to decode Oasis' internal encoded keys to
-// something we can work with locally.
+// something we can work with locally. It never talks to the node, so unlike
+// the rest of the API it takes no context.
 func (oasis *Oasis) DecodeKey(id string) (Address, error) {
 	var address Address
 	err := address.UnmarshalText([]byte(id))
@@ -189,130 +350,150 @@ func (oasis *Oasis) Now() API {
 // API
 // -----------------------------------------------------------------------------
 
-// Account extracts a full snapshot state of an account from the genesis block
-// at a given height.
-func (oasis *Oasis) Account(id Address) (*Account, error) {
-	self := oasis.freezeChain()
+// Account extracts a full snapshot state of an account at the height of the
+// view. When the ledger has not been dumped, the account and its delegations
+// are queried at the height instead.
+func (oasis *Oasis) Account(ctx context.Context, id Address) (*Account, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, err
+	}
 
-	// Attempt to retrieve Account from Genesis State.
-	account, ok := self.State.Snapshot.Ledger[id]
-	if !ok {
-		return nil, fmt.Errorf("No Account with ID: %v", id)
+	if self.State.Snapshot != nil {
+		return ledgerAccount(self.State.Snapshot, self.State.Height, id)
 	}
 
-	// Convert to internal representations
-	stakedBalance := SharePool{
-		Balance:     account.Escrow.Active.Balance.String(),
-		TotalShares: account.Escrow.Active.TotalShares.String(),
+	api := staking.NewStakingClient(self.conn)
+	account, err := api.Account(ctx, &staking.OwnerQuery{Height: self.State.Height, Owner: id})
+	if err != nil {
+		return nil, fmt.Errorf("Account: failed to fetch %v, %w", id, heightUnavailable(err))
 	}
 
-	debondingBalance := SharePool{
-		Balance:     account.Escrow.Debonding.Balance.String(),
-		TotalShares: account.Escrow.Debonding.TotalShares.String(),
+	// The node answers unknown addresses with an empty account, rather than
+	// an error, so treat those as missing as the ledger would.
+	if isEmptyAccount(account) {
+		return nil, fmt.Errorf("No Account with ID: %v, %w", id, ErrNotFound)
 	}
 
-	// Delegations
-	delegations := self.AccountDelegations(id)
+	delegations, err := self.AccountDelegations(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Account: %w", err)
+	}
 
-	return &Account{
-		Address:          id,
-		Balance:          account.General.Balance.String(),
-		StakedBalance:    &stakedBalance,
-		DebondingBalance: &debondingBalance,
-		Height:           self.State.Height,
-		Delegations:      delegations,
-		Meta: AccountMeta{
-			IsValidator: false,
-			IsDelegator: false,
-		},
-	}, nil
+	return convertAccount(account, self.State.Height, id, delegations), nil
 }
 
-func (oasis *Oasis) AccountDelegations(id Address) []Delegation {
-	self := oasis.freezeChain()
-	account, err := self.State.Snapshot.Delegations[id]
-	if !err {
-		return nil
+func (oasis *Oasis) AccountDelegations(ctx context.Context, id Address) ([]Delegation, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, err
+	}
+
+	if self.State.Snapshot != nil {
+		return ledgerAccountDelegations(self.State.Snapshot, id), nil
+	}
+
+	api := staking.NewStakingClient(self.conn)
+	delegations, err := api.Delegations(ctx, &staking.OwnerQuery{Height: self.State.Height, Owner: id})
+	if err != nil {
+		return nil, fmt.Errorf("AccountDelegations: failed to fetch %v, %w", id, heightUnavailable(err))
 	}
 
-	// Convert all delegations for this account into our local types.
-	delegations := []Delegation{}
-	for delegatee, delegation := range account {
-		delegations = append(delegations, Delegation{
-			Delegator: id,
-			Validator: delegatee,
-			Amount:    delegation.Shares,
-		})
+	if len(delegations) == 0 {
+		return nil, nil
 	}
 
-	return delegations
+	return convertDelegations(id, delegations), nil
 }
 
-func (oasis *Oasis) Accounts() []Address {
-	self := oasis.freezeChain()
-	ctx := context.Background()
-	api := staking.NewStakingClient(self.conn)
-	addresses, err := api.Addresses(ctx, self.State.Height)
+// Accounts lists every account in the ledger. Listing accounts is nearly
+// always followed by reading each of them, so the ledger is dumped here rather
+// than querying the node once per account.
+func (oasis *Oasis) Accounts(ctx context.Context) ([]Address, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, err
+	}
 
+	snapshot, err := self.ledger(ctx)
 	if err != nil {
-		fmt.Printf("%+v", err)
-		return nil
+		return nil, fmt.Errorf("Accounts: %w", err)
 	}
 
-	return addresses
+	return ledgerAddresses(snapshot), nil
 }
 
-func (oasis *Oasis) Delegations() []Delegation {
-	self := oasis.freezeChain()
+func (oasis *Oasis) Delegations(ctx context.Context) ([]Delegation, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, err
+	}
 
-	// For Each Account...
-	delegations := []Delegation{}
-	for account := range self.State.Snapshot.Delegations {
-		// ... and each Delegation that Account has done. Append to list.
-		for delegatee := range self.State.Snapshot.Delegations[account] {
-			delegations = append(delegations, Delegation{
-				Delegator: account,
-				Validator: delegatee,
-				Amount:    self.State.Snapshot.Delegations[account][delegatee].Shares,
-			})
-		}
+	snapshot, err := self.ledger(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Delegations: %w", err)
 	}
-	return delegations
+
+	return ledgerDelegations(snapshot), nil
 }
 
-func (oasis *Oasis) GetBlock() (Block, Height) {
-	self := oasis.freezeChain()
-	return *self.State.Block, self.State.Height
+func (oasis *Oasis) GetBlock(ctx context.Context) (Block, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return Block{}, err
+	}
+
+	return *self.State.Block, nil
 }
 
-func (oasis *Oasis) GetEvents() []StakingEvent {
-	self := oasis.freezeChain()
-	ctx := context.Background()
+func (oasis *Oasis) GetEpoch(ctx context.Context) (Epoch, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return 0, err
+	}
+
+	api := consensus.NewConsensusClient(self.conn)
+	epoch, err := api.GetEpoch(ctx, self.State.Height)
+	if err != nil {
+		return 0, fmt.Errorf("GetEpoch: failed to fetch epoch, %w", err)
+	}
+
+	return epoch, nil
+}
+
+func (oasis *Oasis) GetEvents(ctx context.Context) ([]StakingEvent, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, err
+	}
+
 	api := staking.NewStakingClient(self.conn)
 	events, err := api.GetEvents(ctx, self.State.Height)
 	if err != nil {
-		fmt.Printf("GetEvents: failed to fetch txs, %v", err)
-		return nil
+		return nil, fmt.Errorf("GetEvents: failed to fetch events, %w", err)
 	}
 
 	stakingEvents := []StakingEvent{}
-	for _, event := range events {
+	for i, event := range events {
 		stakingEvent := convertEvent(&event)
+		stakingEvent.setIndex(i)
 		stakingEvents = append(stakingEvents, stakingEvent)
 	}
 
-	return stakingEvents
+	return stakingEvents, nil
 }
 
 // GetGenesisState will fetch a full snapshot of the chains state in a format
 // Oasis uses to bootstrap network ugprades. Storing this should allow us to
 // easily pull historical data without going through gRPC.
-func (oasis *Oasis) GetGenesisState() (*Genesis, error) {
-	self := oasis.freezeChain()
-	ctx := context.Background()
+func (oasis *Oasis) GetGenesisState(ctx context.Context) (*Genesis, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, err
+	}
+
 	api := staking.NewStakingClient(self.conn)
 
-	var err error
 	var genesis *staking.Genesis
 	var encoded []byte
 
@@ -327,29 +508,31 @@ func (oasis *Oasis) GetGenesisState() (*Genesis, error) {
 	return &Genesis{encoded}, nil
 }
 
-// GetTransactions pulls decoded Oasis transactions from gRPC.
-func (oasis *Oasis) GetTransactions() []Transaction {
-	self := oasis.freezeChain()
-	ctx := context.Background()
-	api := consensus.NewConsensusClient(self.conn)
-	txs, err := api.GetTransactions(ctx, self.State.Height)
+// GetTransactions pulls decoded Oasis transactions from gRPC, along with their
+// execution results when the node can report them. A transaction that fails
+// to decode fails the whole call, so that a broken decoder stops the extractor
+// instead of silently dropping data.
+func (oasis *Oasis) GetTransactions(ctx context.Context) ([]Transaction, error) {
+	self, err := oasis.freezeChain()
 	if err != nil {
-		fmt.Printf("GetTransactions: failed to fetch txs, %v", err)
-		return nil
+		return nil, err
+	}
+
+	txs, results, err := self.fetchTransactions(ctx, self.State.Height)
+	if err != nil {
+		return nil, fmt.Errorf("GetTransactions: failed to fetch txs, %w", err)
 	}
 
 	decodedTxs := []Transaction{}
-	for _, tx := range txs {
+	for i, tx := range txs {
 		var signedTx signature.Signed
 		var decodeTx transaction.Transaction
 		if err := cbor.Unmarshal(tx, &signedTx); err != nil {
-			log.Printf("GetTransactions: Unmarshal Signed failed, %v", err)
-			return nil
+			return nil, fmt.Errorf("GetTransactions: Unmarshal Signed failed, %w", err)
 		}
 
 		if err := cbor.Unmarshal(signedTx.Blob, &decodeTx); err != nil {
-			log.Printf("GetTransactions: Unmarshal Blob failed, %v", err)
-			return nil
+			return nil, fmt.Errorf("GetTransactions: Unmarshal Blob failed, %w", err)
 		}
 
 		// Create Base Tx Structure
@@ -370,8 +553,7 @@ func (oasis *Oasis) GetTransactions() []Transaction {
 		case staking.MethodTransfer:
 			var cborPayload staking.Transfer
 			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
-				log.Printf("GetTransactions: Unmarshal MethodTransfer Failed, %v", err)
-				return nil
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodTransfer Failed, %w", err)
 			}
 			tx.Payload = &TransferTx{
 				From:   staking.NewAddress(signedTx.Signature.PublicKey),
@@ -382,8 +564,7 @@ func (oasis *Oasis) GetTransactions() []Transaction {
 		case staking.MethodAddEscrow:
 			var cborPayload staking.Escrow
 			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
-				log.Printf("GetTransactions: Unmarshal MethodAddEscrow Failed, %v", err)
-				return nil
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodAddEscrow Failed, %w", err)
 			}
 			tx.Payload = &AddEscrowTx{
 				To:     cborPayload.Account,
@@ -393,8 +574,7 @@ func (oasis *Oasis) GetTransactions() []Transaction {
 		case staking.MethodBurn:
 			var cborPayload staking.Burn
 			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
-				log.Printf("GetTransactions: Unmarshal MethodBurn Failed, %v", err)
-				return nil
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodBurn Failed, %w", err)
 			}
 			tx.Payload = &BurnTx{
 				From:   staking.NewAddress(signedTx.Signature.PublicKey),
@@ -404,46 +584,163 @@ func (oasis *Oasis) GetTransactions() []Transaction {
 		case staking.MethodReclaimEscrow:
 			var cborPayload staking.ReclaimEscrow
 			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
-				log.Printf("GetTransactions: Unmarshal MethodReclaimEscrow Failed, %v", err)
-				return nil
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodReclaimEscrow Failed, %w", err)
 			}
 			tx.Payload = &ReclaimEscrowTx{
 				From:   cborPayload.Account,
 				Shares: cborPayload.Shares,
 			}
 
+		case staking.MethodAmendCommissionSchedule:
+			var cborPayload staking.AmendCommissionSchedule
+			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodAmendCommissionSchedule Failed, %w", err)
+			}
+			payload := &AmendCommissionScheduleTx{
+				Rates:  []Rate{},
+				Bounds: []Bound{},
+			}
+			for _, step := range cborPayload.Amendment.Rates {
+				payload.Rates = append(payload.Rates, Rate{
+					Start: Height(step.Start),
+					Rate:  step.Rate,
+				})
+			}
+			for _, step := range cborPayload.Amendment.Bounds {
+				payload.Bounds = append(payload.Bounds, Bound{
+					Start:   Height(step.Start),
+					RateMin: step.RateMin,
+					RateMax: step.RateMax,
+				})
+			}
+			tx.Payload = payload
+
+		// Registry descriptors are signed blobs. The consensus layer already
+		// checked the signatures before accepting the transaction, so here we
+		// only need to unpack the blob itself.
+		case registry.MethodRegisterEntity:
+			var cborPayload entity.SignedEntity
+			var descriptor entity.Entity
+			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodRegisterEntity Failed, %w", err)
+			}
+			if err := cbor.Unmarshal(cborPayload.Blob, &descriptor); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal Entity Failed, %w", err)
+			}
+			payload := &RegisterEntityTx{
+				ID:                     staking.NewAddress(descriptor.ID),
+				Nodes:                  []Address{},
+				AllowEntitySignedNodes: descriptor.AllowEntitySignedNodes,
+			}
+			for _, nodeID := range descriptor.Nodes {
+				payload.Nodes = append(payload.Nodes, staking.NewAddress(nodeID))
+			}
+			tx.Payload = payload
+
+		case registry.MethodDeregisterEntity:
+			tx.Payload = &DeregisterEntityTx{}
+
+		case registry.MethodRegisterNode:
+			var cborPayload node.MultiSignedNode
+			var descriptor node.Node
+			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodRegisterNode Failed, %w", err)
+			}
+			if err := cbor.Unmarshal(cborPayload.Blob, &descriptor); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal Node Failed, %w", err)
+			}
+			tx.Payload = &RegisterNodeTx{
+				ID:         staking.NewAddress(descriptor.ID),
+				Entity:     staking.NewAddress(descriptor.EntityID),
+				Expiration: descriptor.Expiration,
+			}
+
+		case registry.MethodUnfreezeNode:
+			var cborPayload registry.UnfreezeNode
+			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodUnfreezeNode Failed, %w", err)
+			}
+			tx.Payload = &UnfreezeNodeTx{
+				ID: staking.NewAddress(cborPayload.NodeID),
+			}
+
+		case registry.MethodRegisterRuntime:
+			var cborPayload registry.SignedRuntime
+			var descriptor registry.Runtime
+			if err := cbor.Unmarshal(decodeTx.Body, &cborPayload); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal MethodRegisterRuntime Failed, %w", err)
+			}
+			if err := cbor.Unmarshal(cborPayload.Blob, &descriptor); err != nil {
+				return nil, fmt.Errorf("GetTransactions: Unmarshal Runtime Failed, %w", err)
+			}
+			tx.Payload = &RegisterRuntimeTx{
+				ID:      descriptor.ID[:],
+				Version: descriptor.Version.Version.String(),
+			}
+
 		default:
 			tx.Payload = &UnknownTx{
 				Payload: decodeTx.Body,
 			}
 		}
 
+		// Attach the outcome of executing the transaction, when known.
+		tx.Status = TxStatusUnknown
+		tx.Events = []StakingEvent{}
+		if results != nil {
+			result := results[i]
+			tx.Status = TxStatusSuccess
+			tx.GasUsed = result.GasUsed
+			if result.Error.Code != 0 {
+				tx.Status = TxStatusFailed
+				tx.Error = &TransactionError{
+					Module:  result.Error.Module,
+					Code:    result.Error.Code,
+					Message: result.Error.Message,
+				}
+			}
+
+			for _, event := range result.Events {
+				if event.Staking != nil {
+					tx.Events = append(tx.Events, convertEvent(event.Staking))
+				}
+			}
+		}
+
 		decodedTxs = append(decodedTxs, tx)
 	}
 
-	return decodedTxs
+	return decodedTxs, nil
 }
 
-func (oasis *Oasis) GetValidatorCommission(id Address) (*Amount, *Amount, error) {
-	self := oasis.freezeChain()
-	ctx := context.Background()
+func (oasis *Oasis) GetValidatorCommission(ctx context.Context, id Address) (*Amount, *Amount, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	api := consensus.NewConsensusClient(self.conn)
 
-	// Attempt to retrieve Account from Genesis State.
-	account, ok := self.State.Snapshot.Ledger[id]
-	if !ok {
-		return nil, nil, fmt.Errorf("No Account with ID: %v", id)
+	// Get Current Commission Numerator
+	epochTime, err := api.GetEpoch(ctx, self.State.Height)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetValidatorCommission: failed to fetch epoch, %w", err)
+	}
+
+	snapshot, err := self.ledger(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetValidatorCommission: %w", err)
 	}
 
-	// Get Current Commission Numerator
-	epochTime, _ := api.GetEpoch(ctx, self.State.Height)
-	rate := account.Escrow.CommissionSchedule.CurrentRate(epochTime)
-	return rate, staking.CommissionRateDenominator, nil
+	return ledgerCommission(snapshot, epochTime, id)
 }
 
-func (oasis *Oasis) Pool() (*Pool, error) {
-	self := oasis.freezeChain()
-	ctx := context.Background()
+func (oasis *Oasis) Pool(ctx context.Context) (*Pool, error) {
+	self, err := oasis.freezeChain()
+	if err != nil {
+		return nil, err
+	}
+
 	api := staking.NewStakingClient(self.conn)
 	pool, err := api.CommonPool(ctx, self.State.Height)
 	if err != nil {
@@ -455,13 +752,60 @@ func (oasis *Oasis) Pool() (*Pool, error) {
 // Watchers
 // -----------------------------------------------------------------------------
 
+// Delays between resubscription attempts start at resubscribeMinDelay and
+// double up to resubscribeMaxDelay.
+const (
+	resubscribeMinDelay = time.Second
+	resubscribeMaxDelay = time.Minute
+)
+
+// errStreamClosed is reported when an upstream subscription ends on its own,
+// which usually means the node went away.
+var errStreamClosed = errors.New("upstream stream closed")
+
+// resubscribe calls subscribe until it succeeds, backing off exponentially
+// between attempts. The gRPC connection is told to redial straight away before
+// each attempt rather than waiting out its own backoff. It only gives up when
+// the context is cancelled.
+func (oasis *Oasis) resubscribe(ctx context.Context, what string, subscribe func() error) error {
+	delay := resubscribeMinDelay
+	for {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		oasis.conn.ResetConnectBackoff()
+		err := subscribe()
+		if err == nil {
+			log.Printf("%s: resubscribed\n", what)
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if delay *= 2; delay > resubscribeMaxDelay {
+			delay = resubscribeMaxDelay
+		}
+
+		log.Printf("%s: resubscribe failed, retrying in %s: %v\n", what, delay, err)
+	}
+}
+
 // WatchBlocks wraps the WatchBlocks provided by the Oasis API, and decodes the
-// underlying Tendermint header transparently.
-func (oasis *Oasis) WatchBlocks() (chan Block, error) {
-	self := oasis.freezeChain()
-	ctx := context.Background()
-	api := consensus.NewConsensusClient(self.conn)
-	channel, _, err := api.WatchBlocks(ctx)
+// underlying Tendermint header transparently. Blocks that fail to decode are
+// logged and skipped.
+//
+// When the upstream subscription ends, for example because the node restarted,
+// it is re-established with exponential backoff. Heights missed in between, or
+// skipped by the node, are fetched one by one so the returned channel sees
+// every height in order. The channel is only closed once the context is done.
+func (oasis *Oasis) WatchBlocks(ctx context.Context) (<-chan Block, error) {
+	api := consensus.NewConsensusClient(oasis.conn)
+	channel, subscription, err := api.WatchBlocks(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -470,11 +814,22 @@ func (oasis *Oasis) WatchBlocks() (chan Block, error) {
 
 	// Spawn Proxy Goroutine
 	go func() {
+		defer close(proxyChannel)
+
+		var lastHeight Height
 		for {
-			select {
-			case block := <-channel:
-				tendermintBlock := decodeBlockAsTendermint(block)
-				proxyChannel <- tendermintBlock
+			err := forwardBlocks(ctx, api, channel, proxyChannel, &lastHeight)
+			subscription.Close()
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Printf("WatchBlocks: lost subscription after Block %d, %v\n", lastHeight, err)
+			if oasis.resubscribe(ctx, "WatchBlocks", func() (err error) {
+				channel, subscription, err = api.WatchBlocks(ctx)
+				return err
+			}) != nil {
+				return
 			}
 		}
 	}()
@@ -482,26 +837,117 @@ func (oasis *Oasis) WatchBlocks() (chan Block, error) {
 	return proxyChannel, nil
 }
 
-func (oasis *Oasis) WatchStakingEvents() (chan StakingEvent, error) {
-	self := oasis.freezeChain()
-	ctx := context.Background()
-	api := staking.NewStakingClient(self.conn)
+// forwardBlocks relays blocks from an upstream subscription until it ends.
+// Blocks at or below lastHeight were already delivered and are dropped, while
+// any heights between lastHeight and an incoming block are fetched first.
+func forwardBlocks(ctx context.Context, api consensus.ClientBackend, upstream <-chan *consensus.Block, downstream chan<- Block, lastHeight *Height) error {
+	deliver := func(block *consensus.Block) error {
+		tendermintBlock, err := decodeBlockAsTendermint(block)
+		if err != nil {
+			log.Printf("WatchBlocks: %v\n", err)
+		} else {
+			select {
+			case downstream <- tendermintBlock:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
 
-	var eventChannel <-chan *staking.Event
-	var err error
+		*lastHeight = block.Height
+		return nil
+	}
+
+	for {
+		select {
+		case block, ok := <-upstream:
+			if !ok {
+				return errStreamClosed
+			}
+
+			if block.Height <= *lastHeight {
+				continue
+			}
+
+			for *lastHeight != 0 && *lastHeight+1 < block.Height {
+				missed, err := api.GetBlock(ctx, *lastHeight+1)
+				if err != nil {
+					return fmt.Errorf("failed to fetch missed Block %d, %w", *lastHeight+1, err)
+				}
+
+				if err := deliver(missed); err != nil {
+					return err
+				}
+			}
+
+			if err := deliver(block); err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// eventCursor records how far a staking event stream got, so a resubscribed
+// stream can continue exactly where the previous one stopped.
+type eventCursor struct {
+	height    Height // Height of the last delivered event.
+	delivered int    // Number of events delivered at that height.
+	replayed  Height // Live events up to this height were already replayed.
+}
+
+func (cursor *eventCursor) advance(height Height) {
+	if height != cursor.height {
+		cursor.height = height
+		cursor.delivered = 0
+	}
+
+	cursor.delivered++
+}
+
+// WatchStakingEvents streams staking events as they are emitted by the chain.
+// Like WatchBlocks, the upstream subscription is re-established when it ends,
+// and the events emitted while disconnected are fetched and delivered before
+// any new ones. Resuming needs at least one event to have been delivered, as
+// the stream has no notion of height until then.
+func (oasis *Oasis) WatchStakingEvents(ctx context.Context) (<-chan StakingEvent, error) {
+	api := staking.NewStakingClient(oasis.conn)
 
 	// Watch All Channels
-	if eventChannel, _, err = api.WatchEvents(ctx); err != nil {
+	eventChannel, subscription, err := api.WatchEvents(ctx)
+	if err != nil {
 		return nil, fmt.Errorf("WatchStakingEvents: failed WatchEvents, %w", err)
 	}
 
 	proxyChannel := make(chan StakingEvent)
 
 	go func() {
+		defer close(proxyChannel)
+
+		var cursor eventCursor
 		for {
-			select {
-			case msg := <-eventChannel:
-				proxyChannel <- convertEvent(msg)
+			err := forwardEvents(ctx, eventChannel, proxyChannel, &cursor)
+			subscription.Close()
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Printf("WatchStakingEvents: lost subscription after height %d, %v\n", cursor.height, err)
+			if oasis.resubscribe(ctx, "WatchStakingEvents", func() (err error) {
+				if eventChannel, subscription, err = api.WatchEvents(ctx); err != nil {
+					return err
+				}
+
+				// Replay only once subscribed, so that nothing emitted
+				// during the replay falls between it and the live stream.
+				if err = oasis.replayEvents(ctx, api, proxyChannel, &cursor); err != nil {
+					subscription.Close()
+				}
+
+				return err
+			}) != nil {
+				return
 			}
 		}
 	}()
@@ -509,11 +955,84 @@ func (oasis *Oasis) WatchStakingEvents() (chan StakingEvent, error) {
 	return proxyChannel, nil
 }
 
+// forwardEvents relays events from an upstream subscription until it ends,
+// dropping those already delivered by a replay.
+func forwardEvents(ctx context.Context, upstream <-chan *staking.Event, downstream chan<- StakingEvent, cursor *eventCursor) error {
+	for {
+		select {
+		case msg, ok := <-upstream:
+			if !ok {
+				return errStreamClosed
+			}
+
+			if msg.Height <= cursor.replayed {
+				continue
+			}
+
+			select {
+			case downstream <- convertEvent(msg):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			cursor.advance(msg.Height)
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// replayEvents delivers every event emitted after the cursor, up to the
+// current tip of the chain.
+func (oasis *Oasis) replayEvents(ctx context.Context, api staking.Backend, downstream chan<- StakingEvent, cursor *eventCursor) error {
+	if cursor.height == 0 {
+		return nil
+	}
+
+	tip, err := consensus.NewConsensusClient(oasis.conn).GetBlock(ctx, consensus.HeightLatest)
+	if err != nil {
+		return fmt.Errorf("replayEvents: failed to fetch tip, %w", err)
+	}
+
+	from, skip := cursor.height, cursor.delivered
+	for height := from; height <= tip.Height; height++ {
+		events, err := api.GetEvents(ctx, height)
+		if err != nil {
+			return fmt.Errorf("replayEvents: failed to fetch events at %d, %w", height, err)
+		}
+
+		// Part of the first height may have gone out before the stream
+		// was lost.
+		if height == from {
+			if skip > len(events) {
+				skip = len(events)
+			}
+			events = events[skip:]
+		}
+
+		for _, event := range events {
+			event := event
+			select {
+			case downstream <- convertEvent(&event):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			cursor.advance(height)
+		}
+	}
+
+	cursor.replayed = tip.Height
+	return nil
+}
+
 func convertEvent(event *staking.Event) StakingEvent {
 	switch {
 	case event.Transfer != nil:
 		return StakingEvent{
 			Transfer: &TransferEvent{
+				Height: event.Height,
 				Hash:   event.TxHash.String(),
 				From:   event.Transfer.From,
 				To:     event.Transfer.To,
@@ -524,6 +1043,7 @@ func convertEvent(event *staking.Event) StakingEvent {
 	case event.Burn != nil:
 		return StakingEvent{
 			Burn: &BurnEvent{
+				Height: event.Height,
 				Hash:   event.TxHash.String(),
 				Owner:  event.Burn.Owner,
 				Tokens: event.Burn.Tokens,
@@ -541,12 +1061,14 @@ func convertEvent(event *staking.Event) StakingEvent {
 				Owner:  event.Escrow.Add.Owner,
 				Escrow: event.Escrow.Add.Escrow,
 				Tokens: event.Escrow.Add.Tokens,
+				Height: event.Height,
 				Hash:   event.TxHash.String(),
 			}
 		case event.Escrow.Take != nil:
 			takeEvent = &TakeEscrowEvent{
 				Owner:  event.Escrow.Take.Owner,
 				Tokens: event.Escrow.Take.Tokens,
+				Height: event.Height,
 				Hash:   event.TxHash.String(),
 			}
 		case event.Escrow.Reclaim != nil:
@@ -554,6 +1076,7 @@ func convertEvent(event *staking.Event) StakingEvent {
 				Owner:  event.Escrow.Reclaim.Owner,
 				Escrow: event.Escrow.Reclaim.Escrow,
 				Tokens: event.Escrow.Reclaim.Tokens,
+				Height: event.Height,
 				Hash:   event.TxHash.String(),
 			}
 		}
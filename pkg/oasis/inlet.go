@@ -8,9 +8,13 @@
 package oasis
 
 import (
+	"bufio"
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
@@ -32,6 +36,7 @@ type Batch = []Query
 type Query struct {
 	Query string        `json:"query"`
 	Args  []interface{} `json:"args"`
+	Types []string      `json:"types,omitempty"` // Argument types, recorded when written to disk.
 }
 
 type Inlet struct {
@@ -40,6 +45,7 @@ type Inlet struct {
 	db           *sql.DB           // Underlying Database.
 	errHandler   func(error)       // Function to handle errors in the background goroutine.
 	mu           sync.Mutex        // Lock access to this structure in concurrent functions.
+	pending      sync.WaitGroup    // Tracks batches sent to the background goroutine but not yet written.
 	queries      Batch             // Current batch of queries.
 	syncAt       int               // Height to create a new batch at.
 	writeHandler func(Batch) error // Function to handle query writes
@@ -90,6 +96,8 @@ func InitInlet(db *sql.DB, syncAt int, errHandler func(error), writeHandler func
 						log.Println("Batching to DB")
 						batchToDB(batch)
 					}
+					inlet.pending.Done()
+					metricInletQueueDepth.Dec()
 				}
 			}
 		}()
@@ -104,15 +112,40 @@ func PushQuery(query string, args ...interface{}) {
 	defer inlet.mu.Unlock()
 
 	// Append to batch, then forward batch if full.
-	inlet.queries = append(inlet.queries, Query{query, args})
+	inlet.queries = append(inlet.queries, Query{Query: query, Args: args})
 	log.Printf("Batch Size: %v\n", len(inlet.queries))
 	if len(inlet.queries) == inlet.syncAt {
 		log.Printf("Pushing Batch of %d Queries\n", len(inlet.queries))
+		inlet.pending.Add(1)
+		metricInletQueueDepth.Inc()
 		inlet.channel <- inlet.queries
 		inlet.queries = make(Batch, 0, inlet.syncAt)
 	}
 }
 
+// WaitInlet pushes any partially filled batch and blocks until every batch has
+// been written. This should be called before exiting from short lived commands
+// so that queued queries are not lost.
+func WaitInlet() {
+	inlet.mu.Lock()
+	if len(inlet.queries) > 0 {
+		log.Printf("Pushing Batch of %d Queries\n", len(inlet.queries))
+		inlet.pending.Add(1)
+		metricInletQueueDepth.Inc()
+		inlet.channel <- inlet.queries
+		inlet.queries = make(Batch, 0, inlet.syncAt)
+	}
+	inlet.mu.Unlock()
+
+	inlet.pending.Wait()
+}
+
+// InletToDisk reports whether the Inlet has failed over to writing batches to
+// disk, which it never recovers from without a restart.
+func InletToDisk() bool {
+	return inlet != nil && atomic.LoadUint64(&inlet.writeMode) == TO_DISK
+}
+
 // -----------------------------------------------------------------------------
 // Private Functions
 
@@ -128,6 +161,7 @@ func batchToDB(queries Batch) {
 
 	if err := inlet.writeHandler(queries); err != nil {
 		atomic.StoreUint64(&inlet.writeMode, TO_DISK)
+		metricInletToDisk.Set(1)
 		log.Println("Batch Write Failed")
 		inlet.errHandler(err)
 		batchToDisk(queries)
@@ -160,7 +194,7 @@ func getReference() *Inlet {
 // There are cases where writing a batch may fail. There may be a networking
 // issue, the database might be full, or a rare transaction collision. Instead of
 // just exploding, we'll start writing batches to disk when this happens and name
-// them sequentially so we can recover manually.
+// them sequentially so they can be replayed later, see `hippias inlet replay`.
 
 func check(err error) {
 	if err != nil {
@@ -177,10 +211,164 @@ func batchToDisk(queries Batch) {
 	defer file.Close()
 	check(err)
 
-	// Write queries as JSON.
+	// Write queries as JSON, one per line. JSON loses the Go type of some
+	// arguments ([]byte becomes base64, time.Time a string), so the types are
+	// recorded alongside to restore them.
 	encoder := json.NewEncoder(file)
 	for _, q := range queries {
+		q.Types = make([]string, len(q.Args))
+		for i, arg := range q.Args {
+			q.Types[i] = argType(arg)
+		}
+
 		err := encoder.Encode(q)
 		check(err)
 	}
 }
+
+// Argument types recorded in batch files.
+const (
+	argNull   = "null"
+	argBytes  = "bytes"
+	argTime   = "time"
+	argString = "string"
+	argBool   = "bool"
+	argInt    = "int"
+	argFloat  = "float"
+	argJSON   = "json" // Anything else, restored as decoded from JSON.
+)
+
+func argType(arg interface{}) string {
+	switch arg.(type) {
+	case nil:
+		return argNull
+	case []byte:
+		return argBytes
+	case time.Time:
+		return argTime
+	case string:
+		return argString
+	case bool:
+		return argBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return argInt
+	case float32, float64:
+		return argFloat
+	default:
+		return argJSON
+	}
+}
+
+// ReadBatchFile reads back a batch written to disk by the Inlet. Arguments are
+// restored to the types they were pushed with. Files written before types were
+// recorded are still read, but []byte arguments come back as base64 strings.
+func ReadBatchFile(path string) (Batch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	type diskQuery struct {
+		Query string            `json:"query"`
+		Args  []json.RawMessage `json:"args"`
+		Types []string          `json:"types"`
+	}
+
+	batch := Batch{}
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for line := 1; ; line++ {
+		var encoded diskQuery
+		if err := decoder.Decode(&encoded); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("query %d: %w", line, err)
+		}
+
+		if encoded.Query == "" {
+			return nil, fmt.Errorf("query %d: no query name", line)
+		}
+
+		if encoded.Types != nil && len(encoded.Types) != len(encoded.Args) {
+			return nil, fmt.Errorf("query %d: %d arguments but %d types", line, len(encoded.Args), len(encoded.Types))
+		}
+
+		query := Query{Query: encoded.Query, Args: make([]interface{}, len(encoded.Args)), Types: encoded.Types}
+		for i, raw := range encoded.Args {
+			kind := argJSON
+			if encoded.Types != nil {
+				kind = encoded.Types[i]
+			}
+
+			if query.Args[i], err = decodeArg(kind, raw); err != nil {
+				return nil, fmt.Errorf("query %d (%s) argument %d: %w", line, encoded.Query, i+1, err)
+			}
+		}
+
+		batch = append(batch, query)
+	}
+
+	return batch, nil
+}
+
+// decodeArg restores an argument of a recorded type from its JSON encoding.
+func decodeArg(kind string, raw json.RawMessage) (interface{}, error) {
+	switch kind {
+	case argNull:
+		return nil, nil
+
+	case argBytes:
+		var value []byte
+		err := json.Unmarshal(raw, &value)
+		return value, err
+
+	case argTime:
+		var value time.Time
+		err := json.Unmarshal(raw, &value)
+		return value, err
+
+	case argString:
+		var value string
+		err := json.Unmarshal(raw, &value)
+		return value, err
+
+	case argBool:
+		var value bool
+		err := json.Unmarshal(raw, &value)
+		return value, err
+
+	case argInt:
+		var value int64
+		err := json.Unmarshal(raw, &value)
+		return value, err
+
+	case argFloat:
+		var value float64
+		err := json.Unmarshal(raw, &value)
+		return value, err
+
+	case argJSON:
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		switch value := value.(type) {
+		case nil, string, bool:
+			return value, nil
+		case json.Number:
+			if integer, err := value.Int64(); err == nil {
+				return integer, nil
+			}
+			return value.Float64()
+		default:
+			return nil, fmt.Errorf("unsupported value %s", raw)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown argument type %q", kind)
+	}
+}
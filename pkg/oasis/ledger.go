@@ -0,0 +1,134 @@
+// This file converts Oasis staking ledger state into this packages local
+// types. Any API implementation that holds a copy of the staking state (the
+// gRPC implementation keeps one in sync with the chain) can share these so
+// that every implementation returns identically shaped data.
+
+package oasis
+
+import (
+	"fmt"
+	"sort"
+
+	epochtime "github.com/oasisprotocol/oasis-core/go/epochtime/api"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+)
+
+// ledgerAccount extracts a full snapshot of an account from a staking state.
+func ledgerAccount(snapshot *staking.Genesis, height Height, id Address) (*Account, error) {
+	account, ok := snapshot.Ledger[id]
+	if !ok {
+		return nil, fmt.Errorf("No Account with ID: %v, %w", id, ErrNotFound)
+	}
+
+	return convertAccount(account, height, id, ledgerAccountDelegations(snapshot, id)), nil
+}
+
+// convertAccount converts a staking account, along with the delegations made
+// by it, into the local representation.
+func convertAccount(account *staking.Account, height Height, id Address, delegations []Delegation) *Account {
+	stakedBalance := SharePool{
+		Balance:     account.Escrow.Active.Balance.String(),
+		TotalShares: account.Escrow.Active.TotalShares.String(),
+	}
+
+	debondingBalance := SharePool{
+		Balance:     account.Escrow.Debonding.Balance.String(),
+		TotalShares: account.Escrow.Debonding.TotalShares.String(),
+	}
+
+	return &Account{
+		Address:          id,
+		Balance:          account.General.Balance.String(),
+		StakedBalance:    &stakedBalance,
+		DebondingBalance: &debondingBalance,
+		Height:           height,
+		Delegations:      delegations,
+		Meta: AccountMeta{
+			IsValidator: false,
+			IsDelegator: false,
+		},
+	}
+}
+
+// isEmptyAccount reports whether an account holds nothing at all, which is how
+// the node describes addresses that are not in the ledger.
+func isEmptyAccount(account *staking.Account) bool {
+	return account.General.Balance.IsZero() &&
+		account.General.Nonce == 0 &&
+		account.Escrow.Active.Balance.IsZero() &&
+		account.Escrow.Active.TotalShares.IsZero() &&
+		account.Escrow.Debonding.Balance.IsZero() &&
+		account.Escrow.Debonding.TotalShares.IsZero() &&
+		len(account.Escrow.CommissionSchedule.Rates) == 0 &&
+		len(account.Escrow.CommissionSchedule.Bounds) == 0 &&
+		len(account.Escrow.StakeAccumulator.Claims) == 0
+}
+
+// ledgerAccountDelegations lists all delegations made by a single account.
+func ledgerAccountDelegations(snapshot *staking.Genesis, id Address) []Delegation {
+	account, ok := snapshot.Delegations[id]
+	if !ok {
+		return nil
+	}
+
+	return convertDelegations(id, account)
+}
+
+// convertDelegations converts the delegations made by a single account into
+// our local types.
+func convertDelegations(id Address, account map[Address]*staking.Delegation) []Delegation {
+	delegations := []Delegation{}
+	for delegatee, delegation := range account {
+		delegations = append(delegations, Delegation{
+			Delegator: id,
+			Validator: delegatee,
+			Amount:    delegation.Shares,
+		})
+	}
+
+	return delegations
+}
+
+// ledgerDelegations lists every delegation made by every account.
+func ledgerDelegations(snapshot *staking.Genesis) []Delegation {
+	// For Each Account...
+	delegations := []Delegation{}
+	for account := range snapshot.Delegations {
+		// ... and each Delegation that Account has done. Append to list.
+		for delegatee := range snapshot.Delegations[account] {
+			delegations = append(delegations, Delegation{
+				Delegator: account,
+				Validator: delegatee,
+				Amount:    snapshot.Delegations[account][delegatee].Shares,
+			})
+		}
+	}
+	return delegations
+}
+
+// ledgerAddresses lists every address in the ledger, sorted so that callers
+// iterating over the result see a stable order.
+func ledgerAddresses(snapshot *staking.Genesis) []Address {
+	addresses := make([]Address, 0, len(snapshot.Ledger))
+	for address := range snapshot.Ledger {
+		addresses = append(addresses, address)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].String() < addresses[j].String()
+	})
+
+	return addresses
+}
+
+// ledgerCommission returns the commission rate numerator and denominator that
+// applies to a validator at the given epoch.
+func ledgerCommission(snapshot *staking.Genesis, epoch epochtime.EpochTime, id Address) (*Amount, *Amount, error) {
+	account, ok := snapshot.Ledger[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("No Account with ID: %v", id)
+	}
+
+	rate := account.Escrow.CommissionSchedule.CurrentRate(epoch)
+	return rate, staking.CommissionRateDenominator, nil
+}
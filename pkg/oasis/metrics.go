@@ -0,0 +1,72 @@
+// Prometheus metrics for the node connection and the Inlet. Metrics are
+// registered with the default Prometheus registry, so any process using this
+// package only needs to serve promhttp.Handler() to expose them.
+
+package oasis
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	metricTipHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hippias_chain_tip_height",
+		Help: "Height of the most recent block seen from the node.",
+	})
+
+	metricGRPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hippias_grpc_request_duration_seconds",
+		Help:    "Latency of unary gRPC calls to the node, by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	metricGRPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hippias_grpc_errors_total",
+		Help: "gRPC calls to the node that failed, by method and status code.",
+	}, []string{"method", "code"})
+
+	metricGRPCStreams = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hippias_grpc_streams_total",
+		Help: "gRPC streams opened to the node, by method and status code.",
+	}, []string{"method", "code"})
+
+	metricInletQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hippias_inlet_queue_depth",
+		Help: "Batches handed to the Inlet that have not been written yet.",
+	})
+
+	metricInletToDisk = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hippias_inlet_to_disk",
+		Help: "Set to 1 once the Inlet has failed over to writing batches to disk.",
+	})
+)
+
+// unaryMetrics records the latency and outcome of every unary gRPC call.
+func unaryMetrics(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	began := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	metricGRPCDuration.WithLabelValues(method).Observe(time.Since(began).Seconds())
+	if err != nil {
+		metricGRPCErrors.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+
+	return err
+}
+
+// streamMetrics counts attempts to open gRPC streams, such as the block and
+// event subscriptions.
+func streamMetrics(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	metricGRPCStreams.WithLabelValues(method, status.Code(err).String()).Inc()
+	if err != nil {
+		metricGRPCErrors.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+
+	return stream, err
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	epochtime "github.com/oasisprotocol/oasis-core/go/epochtime/api"
 	"github.com/oasisprotocol/oasis-core/go/staking/api"
 )
 
@@ -21,6 +22,10 @@ type Amount = quantity.Quantity
 // Height of the chain is a simple integer.
 type Height = int64
 
+// Epoch is the number of the epoch a block belongs to, validator sets and
+// commission schedules change only on epoch transitions.
+type Epoch = epochtime.EpochTime
+
 // Pool represents the total quantity of currency in the shared pool of rewards.
 type Pool = quantity.Quantity
 
@@ -140,19 +145,39 @@ type AmendCommissionScheduleTx struct {
 }
 
 type UnknownTx struct {
-	Payload []byte `json:"-"`
+	Payload []byte `json:"payload"`
+}
+
+// Execution status of a transaction. Nodes that cannot report transaction
+// results leave every transaction as TxStatusUnknown.
+const (
+	TxStatusSuccess = "success"
+	TxStatusFailed  = "failed"
+	TxStatusUnknown = "unknown"
+)
+
+// TransactionError describes why a transaction failed, using the error module
+// and code registered by the Oasis module that rejected it.
+type TransactionError struct {
+	Module  string `json:"module"`
+	Code    uint32 `json:"code"`
+	Message string `json:"message"`
 }
 
 // Transaction represents a deserialized oasis transaction, this format
 // is also CBOR serialized within Oasis.
 type Transaction struct {
-	Fee      Amount      `json:"fee"`       // Amount Paid for Tx
-	GasPrice Amount      `json:"gas_price"` // Implied Gas Price
-	Hash     string      `json:"hash"`      // Hash of transaction bytes
-	Gas      uint64      `json:"gas"`       // Max Gas Allowed
-	Method   string      `json:"method"`    // Which Method does this Tx invoke.
-	Sender   Address     `json:"sender"`    // Who submitted the Tx
-	Payload  interface{} `json:"data"`      // Actual Payload of TX
+	Fee      Amount            `json:"fee"`             // Amount Paid for Tx
+	GasPrice Amount            `json:"gas_price"`       // Implied Gas Price
+	Hash     string            `json:"hash"`            // Hash of transaction bytes
+	Gas      uint64            `json:"gas"`             // Max Gas Allowed
+	Method   string            `json:"method"`          // Which Method does this Tx invoke.
+	Sender   Address           `json:"sender"`          // Who submitted the Tx
+	Payload  interface{}       `json:"data"`            // Actual Payload of TX
+	Status   string            `json:"status"`          // One of the TxStatus constants.
+	Error    *TransactionError `json:"error,omitempty"` // Why the Tx failed, if it did.
+	GasUsed  uint64            `json:"gas_used"`        // Gas consumed, if reported by the node.
+	Events   []StakingEvent    `json:"events"`          // Staking events emitted by the Tx.
 }
 
 type TransactionPayload struct {
@@ -173,20 +198,22 @@ type TransactionPayload struct {
 // -----------------------------------------------------------------------------
 
 type TransferEvent struct {
-	From   Address   `json:"from"`
-	To     Address   `json:"to"`
-	Tokens Amount    `json:"tokens"`
-	Height Height    `json:"height,omitempty"`
-	Date   time.Time `json:"date,omitempty"`
-	Hash   string    `json:"hash,omitempty"`
+	From   Address    `json:"from"`
+	To     Address    `json:"to"`
+	Tokens Amount     `json:"tokens"`
+	Height Height     `json:"height,omitempty"`
+	Date   *time.Time `json:"date,omitempty"`
+	Hash   string     `json:"hash,omitempty"`
+	Index  *int       `json:"index,omitempty"`
 }
 
 type BurnEvent struct {
 	Owner  Address           `json:"owner"`
 	Tokens quantity.Quantity `json:"tokens"`
 	Height Height            `json:"height,omitempty"`
-	Date   time.Time         `json:"date,omitempty"`
+	Date   *time.Time        `json:"date,omitempty"`
 	Hash   string            `json:"hash,omitempty"`
+	Index  *int              `json:"index,omitempty"`
 }
 
 type EscrowEvent struct {
@@ -204,6 +231,23 @@ type StakingEvent struct {
 	Unknown  *UnknownEvent  `json:"unknown,omitempty"`
 }
 
+// setIndex records the position of an event within the list of events of its
+// block, the same index the extractor stores it under.
+func (event StakingEvent) setIndex(index int) {
+	switch {
+	case event.Transfer != nil:
+		event.Transfer.Index = &index
+	case event.Burn != nil:
+		event.Burn.Index = &index
+	case event.Escrow != nil && event.Escrow.Add != nil:
+		event.Escrow.Add.Index = &index
+	case event.Escrow != nil && event.Escrow.Take != nil:
+		event.Escrow.Take.Index = &index
+	case event.Escrow != nil && event.Escrow.Reclaim != nil:
+		event.Escrow.Reclaim.Index = &index
+	}
+}
+
 // EscrowEvent Discriminants
 
 type AddEscrowEvent struct {
@@ -211,14 +255,18 @@ type AddEscrowEvent struct {
 	Escrow Address           `json:"escrow"`
 	Tokens quantity.Quantity `json:"tokens"`
 	Height Height            `json:"height,omitempty"`
+	Date   *time.Time        `json:"date,omitempty"`
 	Hash   string            `json:"hash,omitempty"`
+	Index  *int              `json:"index,omitempty"`
 }
 
 type TakeEscrowEvent struct {
 	Owner  Address           `json:"owner"`
 	Tokens quantity.Quantity `json:"tokens"`
 	Height Height            `json:"height,omitempty"`
+	Date   *time.Time        `json:"date,omitempty"`
 	Hash   string            `json:"hash,omitempty"`
+	Index  *int              `json:"index,omitempty"`
 }
 
 type ReclaimEscrowEvent struct {
@@ -226,5 +274,7 @@ type ReclaimEscrowEvent struct {
 	Escrow Address           `json:"escrow"`
 	Tokens quantity.Quantity `json:"tokens"`
 	Height Height            `json:"height,omitempty"`
+	Date   *time.Time        `json:"date,omitempty"`
 	Hash   string            `json:"hash,omitempty"`
+	Index  *int              `json:"index,omitempty"`
 }
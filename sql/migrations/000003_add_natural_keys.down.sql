@@ -0,0 +1,17 @@
+BEGIN;
+
+-- Drop the natural keys and the index columns they rely on. Rows deduplicated
+-- by the up migration are not restored.
+ALTER TABLE public.transfers         DROP CONSTRAINT IF EXISTS transfers_natural_key;
+ALTER TABLE public.burns             DROP CONSTRAINT IF EXISTS burns_natural_key;
+ALTER TABLE public.escrow_changes    DROP CONSTRAINT IF EXISTS escrow_changes_natural_key;
+ALTER TABLE public.transactions      DROP CONSTRAINT IF EXISTS transactions_natural_key;
+ALTER TABLE public.account_snapshots DROP CONSTRAINT IF EXISTS account_snapshots_natural_key;
+ALTER TABLE public.genesis_snapshots DROP CONSTRAINT IF EXISTS genesis_snapshots_natural_key;
+
+ALTER TABLE public.transfers         DROP COLUMN IF EXISTS event_index;
+ALTER TABLE public.burns             DROP COLUMN IF EXISTS event_index;
+ALTER TABLE public.escrow_changes    DROP COLUMN IF EXISTS event_index;
+ALTER TABLE public.transactions      DROP COLUMN IF EXISTS tx_index;
+
+COMMIT;
@@ -0,0 +1,137 @@
+BEGIN;
+
+-- Every table written by the extractor gets a natural key, so that processing
+-- the same height twice (after a crash, or when backfilling a range) updates
+-- rows in place rather than inserting duplicates. The insert queries use these
+-- keys as their ON CONFLICT targets.
+--
+-- Events are keyed by their height and their index within the list of events
+-- the node returns for that block. Transactions are keyed by their hash, and
+-- also gain their index within the block for ordering. Snapshots are keyed by
+-- the account (or chain) and height they were taken at.
+
+--------------------------------------------------------------------------------
+
+-- Remove duplicates already created by reprocessing heights, keeping the first
+-- row written for each.
+DELETE FROM public.transfers a
+USING       public.transfers b
+WHERE       a.id     >  b.id     AND
+            a.height =  b.height AND
+            a.hash   =  b.hash   AND
+            a."from" =  b."from" AND
+            a."to"   =  b."to"   AND
+            a.tokens =  b.tokens;
+
+DELETE FROM public.burns a
+USING       public.burns b
+WHERE       a.id     >  b.id     AND
+            a.height =  b.height AND
+            a.hash   =  b.hash   AND
+            a.owner  =  b.owner  AND
+            a.tokens =  b.tokens;
+
+DELETE FROM public.escrow_changes a
+USING       public.escrow_changes b
+WHERE       a.id     >  b.id     AND
+            a.height =  b.height AND
+            a.hash   =  b.hash   AND
+            a.kind   =  b.kind   AND
+            a.owner  =  b.owner  AND
+            a.escrow =  b.escrow AND
+            a.tokens =  b.tokens;
+
+DELETE FROM public.transactions a
+USING       public.transactions b
+WHERE       a.id   > b.id AND
+            a.hash = b.hash;
+
+DELETE FROM public.account_snapshots a
+USING       public.account_snapshots b
+WHERE       a.id      >  b.id      AND
+            a.address =  b.address AND
+            a.height  =  b.height;
+
+DELETE FROM public.genesis_snapshots a
+USING       public.genesis_snapshots b
+WHERE       a.id     > b.id AND
+            a.height = b.height;
+
+--------------------------------------------------------------------------------
+
+-- Number existing events within their block. The original interleaving of
+-- event kinds within a block was never stored, so existing rows are numbered
+-- by hash and then insertion order. These numbers need not match the order the
+-- node returns, so the extractor removes every event at a height before writing
+-- it again rather than relying on the keys below to replace them in place.
+CREATE TEMPORARY TABLE legacy_event_index ON COMMIT DROP AS
+SELECT source,
+       id,
+       row_number() OVER (PARTITION BY height ORDER BY hash, source, id) - 1 AS event_index
+FROM (
+    SELECT 'transfers'      AS source, id, height, hash FROM public.transfers
+    UNION ALL
+    SELECT 'burns'          AS source, id, height, hash FROM public.burns
+    UNION ALL
+    SELECT 'escrow_changes' AS source, id, height, hash FROM public.escrow_changes
+) AS events;
+
+ALTER TABLE  public.transfers
+ADD COLUMN   event_index           integer;
+
+ALTER TABLE  public.burns
+ADD COLUMN   event_index           integer;
+
+ALTER TABLE  public.escrow_changes
+ADD COLUMN   event_index           integer;
+
+UPDATE public.transfers t
+SET    event_index = l.event_index
+FROM   legacy_event_index l
+WHERE  l.source = 'transfers' AND l.id = t.id;
+
+UPDATE public.burns b
+SET    event_index = l.event_index
+FROM   legacy_event_index l
+WHERE  l.source = 'burns' AND l.id = b.id;
+
+UPDATE public.escrow_changes e
+SET    event_index = l.event_index
+FROM   legacy_event_index l
+WHERE  l.source = 'escrow_changes' AND l.id = e.id;
+
+ALTER TABLE  public.transfers
+ALTER COLUMN event_index           SET NOT NULL;
+
+ALTER TABLE  public.burns
+ALTER COLUMN event_index           SET NOT NULL;
+
+ALTER TABLE  public.escrow_changes
+ALTER COLUMN event_index           SET NOT NULL;
+
+-- Number existing transactions within their block in insertion order, which
+-- matches the order the extractor has always written them in.
+ALTER TABLE  public.transactions
+ADD COLUMN   tx_index              integer;
+
+UPDATE public.transactions t
+SET    tx_index = n.tx_index
+FROM   (
+    SELECT id, row_number() OVER (PARTITION BY height ORDER BY id) - 1 AS tx_index
+    FROM   public.transactions
+) AS n
+WHERE  n.id = t.id;
+
+ALTER TABLE  public.transactions
+ALTER COLUMN tx_index              SET NOT NULL;
+
+--------------------------------------------------------------------------------
+
+ALTER TABLE ONLY public.transfers         ADD CONSTRAINT transfers_natural_key         UNIQUE (height, event_index);
+ALTER TABLE ONLY public.burns             ADD CONSTRAINT burns_natural_key             UNIQUE (height, event_index);
+ALTER TABLE ONLY public.escrow_changes    ADD CONSTRAINT escrow_changes_natural_key    UNIQUE (height, event_index);
+ALTER TABLE ONLY public.transactions      ADD CONSTRAINT transactions_natural_key      UNIQUE (hash);
+ALTER TABLE ONLY public.account_snapshots ADD CONSTRAINT account_snapshots_natural_key UNIQUE (address, height);
+ALTER TABLE ONLY public.genesis_snapshots ADD CONSTRAINT genesis_snapshots_natural_key UNIQUE (height);
+
+COMMIT;
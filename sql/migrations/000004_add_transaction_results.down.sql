@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Drop the transaction result columns.
+ALTER TABLE  public.transactions
+DROP COLUMN  IF EXISTS status,
+DROP COLUMN  IF EXISTS error_module,
+DROP COLUMN  IF EXISTS error_code,
+DROP COLUMN  IF EXISTS error_message,
+DROP COLUMN  IF EXISTS gas_used,
+DROP COLUMN  IF EXISTS events;
+
+COMMIT;
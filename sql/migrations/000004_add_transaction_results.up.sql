@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Record the outcome of executing each transaction. Rows written before this
+-- migration, or by a node that cannot report results, keep the 'unknown'
+-- status and leave the remaining columns empty.
+ALTER TABLE  public.transactions
+ADD COLUMN   status                TEXT    NOT NULL DEFAULT 'unknown',
+ADD COLUMN   error_module          TEXT,
+ADD COLUMN   error_code            INTEGER,
+ADD COLUMN   error_message         TEXT,
+ADD COLUMN   gas_used              BIGINT,
+ADD COLUMN   events                JSONB;
+
+COMMIT;
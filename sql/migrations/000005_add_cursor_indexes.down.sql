@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS public.transactions_cursor_idx;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- Cursor pagination walks transactions by (height, tx_index). Events and
+-- account snapshots are already covered by the indexes behind their natural
+-- keys.
+CREATE INDEX IF NOT EXISTS transactions_cursor_idx
+ON     public.transactions (height, tx_index);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS public.account_snapshots_height_idx;
+
+COMMIT;
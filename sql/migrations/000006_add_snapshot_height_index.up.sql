@@ -0,0 +1,8 @@
+BEGIN;
+
+-- Point-in-time reads look snapshots up by height alone, which the natural key
+-- on (address, height) cannot serve.
+CREATE INDEX IF NOT EXISTS account_snapshots_height_idx
+ON     public.account_snapshots (height);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS public.webhook_deliveries;
+DROP TABLE IF EXISTS public.webhooks;
+
+COMMIT;
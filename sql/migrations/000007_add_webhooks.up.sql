@@ -0,0 +1,41 @@
+BEGIN;
+
+-- Webhooks are HTTP callbacks registered for a set of addresses and event
+-- kinds. Deliveries are written in the same transaction as the block that
+-- produced them, then sent (and retried) by the dispatcher, so the table also
+-- serves as the delivery log.
+CREATE TABLE IF NOT EXISTS public.webhooks(
+    id             SERIAL      PRIMARY KEY,
+    url            TEXT        NOT NULL,
+    secret         TEXT        NOT NULL,
+    addresses      TEXT[]      NOT NULL,
+    kinds          TEXT[]      NOT NULL,
+    created        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A delivery is identified by the event that caused it, so reprocessing a
+-- height does not notify anyone twice.
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries(
+    id             BIGSERIAL   PRIMARY KEY,
+    webhook_id     INTEGER     NOT NULL REFERENCES public.webhooks(id) ON DELETE CASCADE,
+    height         BIGINT      NOT NULL,
+    event_index    INTEGER     NOT NULL,
+    kind           TEXT        NOT NULL,
+    address        TEXT        NOT NULL,
+    payload        JSONB       NOT NULL,
+    status         TEXT        NOT NULL DEFAULT 'pending',
+    attempts       INTEGER     NOT NULL DEFAULT 0,
+    next_attempt   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    response_code  INTEGER,
+    last_error     TEXT,
+    created        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered      TIMESTAMPTZ,
+
+    CONSTRAINT webhook_deliveries_natural_key UNIQUE (webhook_id, height, event_index, kind, address)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx
+ON     public.webhook_deliveries (next_attempt)
+WHERE  status = 'pending';
+
+COMMIT;
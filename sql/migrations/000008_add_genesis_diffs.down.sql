@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS public.genesis_snapshots_base_height_idx;
+ALTER TABLE public.genesis_snapshots DROP COLUMN IF EXISTS base_height;
+
+COMMIT;
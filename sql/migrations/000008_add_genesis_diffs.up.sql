@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Genesis snapshots are stored either in full, or as a JSON merge patch against
+-- an earlier full snapshot. base_height names that snapshot, and is NULL for
+-- snapshots stored in full.
+ALTER TABLE public.genesis_snapshots
+ADD COLUMN IF NOT EXISTS base_height integer;
+
+CREATE INDEX IF NOT EXISTS genesis_snapshots_base_height_idx
+ON     public.genesis_snapshots (base_height);
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+-- Account and genesis snapshots are too slow to take inside the transaction of
+-- the block that schedules them. Instead a job is written in that transaction,
+-- and taken afterwards in a transaction of its own that also marks it done, so
+-- a block that fails to commit never leaves a snapshot scheduled, and a
+-- scheduled snapshot is never lost if the process stops before taking it.
+CREATE TABLE IF NOT EXISTS public.snapshot_jobs(
+    id             BIGSERIAL   PRIMARY KEY,
+    kind           TEXT        NOT NULL,
+    height         BIGINT      NOT NULL,
+    date           TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+    status         TEXT        NOT NULL DEFAULT 'pending',
+    attempts       INTEGER     NOT NULL DEFAULT 0,
+    next_attempt   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error     TEXT,
+    created        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished       TIMESTAMPTZ,
+
+    CONSTRAINT snapshot_jobs_natural_key UNIQUE (kind, height)
+);
+
+CREATE INDEX IF NOT EXISTS snapshot_jobs_pending_idx
+ON     public.snapshot_jobs (kind, height)
+WHERE  status = 'pending';
+
+COMMIT;
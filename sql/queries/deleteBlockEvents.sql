@@ -0,0 +1,15 @@
+-- Remove every event recorded at a height, so that the events of a block being
+-- written replace whatever an earlier pass wrote for it. Rows written before
+-- events were numbered by the node were given an index by the natural keys
+-- migration that need not match the order the node returns, so upserting on
+-- (height, event_index) alone could leave them next to their replayed copies.
+
+--------------------------------------------------------------------------------
+
+-- name: deleteBlockEvents
+WITH transfers AS (
+    DELETE FROM transfers      WHERE height = $1
+), burns AS (
+    DELETE FROM burns          WHERE height = $1
+)
+DELETE FROM escrow_changes WHERE height = $1;
@@ -0,0 +1,8 @@
+-- Remove a webhook. Its delivery log is removed along with it, including any
+-- deliveries that were still pending.
+
+--------------------------------------------------------------------------------
+
+-- name: deleteWebhook
+DELETE FROM webhooks
+WHERE       id = $1;
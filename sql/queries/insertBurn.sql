@@ -1,9 +1,18 @@
 -- Write a Burn Event to the database, this isn't a full transaction, though a
 -- full transaction should be written to the transactions table that matches
 -- any event here.
+--
+-- Events are keyed by height and their index within the block, so writing the
+-- same event twice overwrites the earlier row. The extractor also clears a
+-- height with deleteBlockEvents before writing its events again.
 
 --------------------------------------------------------------------------------
 
 -- name: insertBurn
-INSERT INTO burns ("owner", "tokens", "hash", "height", "date")
-VALUES            ($1     , $2      , $3    , $4      , $5);
+INSERT INTO burns ("owner", "tokens", "hash", "height", "date", "event_index")
+VALUES            ($1     , $2      , $3    , $4      , $5    , $6)
+ON CONFLICT (height, event_index)
+DO UPDATE   SET owner  = EXCLUDED.owner,
+                tokens = EXCLUDED.tokens,
+                hash   = EXCLUDED.hash,
+                date   = EXCLUDED.date;
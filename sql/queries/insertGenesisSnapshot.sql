@@ -1,9 +1,19 @@
 -- Insert a Genesis Snapshot, these are full JSON dumps of the state of the
 -- chain. These should be stored as diffs when possible to reduce the massive
--- size this would otherwise be.
+-- size this would otherwise be: when base_height is set the snapshot data is a
+-- JSON merge patch against the snapshot stored in full at that height.
+--
+-- Snapshots are keyed by height. Taking the same snapshot twice overwrites an
+-- earlier diff, but never a snapshot stored in full, as other snapshots may be
+-- diffs against it.
 
 --------------------------------------------------------------------------------
 
 -- name: insertGenesisSnapshot
-INSERT INTO genesis_snapshots ("snapshot_data", "height", "date")
-VALUES                        ($1             , $2      , $3);
+INSERT INTO genesis_snapshots ("snapshot_data", "height", "date", "base_height")
+VALUES                        ($1             , $2      , $3    , $4           )
+ON CONFLICT (height)
+DO UPDATE   SET snapshot_data = EXCLUDED.snapshot_data,
+                date          = EXCLUDED.date,
+                base_height   = EXCLUDED.base_height
+WHERE       genesis_snapshots.base_height IS NOT NULL;
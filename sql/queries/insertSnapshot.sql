@@ -1,5 +1,8 @@
 -- Insert an Account snapshot into the database, this row should be equivelent
 -- to the shape of data returned by the /api/account/<address> endpoint.
+--
+-- Snapshots are keyed by address and height, so taking the same snapshot twice
+-- overwrites the earlier row.
 
 --------------------------------------------------------------------------------
 
@@ -16,4 +19,13 @@ INSERT INTO account_snapshots (
     "height",
     "date"
 )
-VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10);
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+ON CONFLICT (address, height)
+DO UPDATE SET balance           = EXCLUDED.balance,
+              staked_balance    = EXCLUDED.staked_balance,
+              debonding_balance = EXCLUDED.debonding_balance,
+              rewards_balance   = EXCLUDED.rewards_balance,
+              delegations       = EXCLUDED.delegations,
+              is_validator      = EXCLUDED.is_validator,
+              is_delegator      = EXCLUDED.is_delegator,
+              date              = EXCLUDED.date;
@@ -0,0 +1,10 @@
+-- Queue a snapshot to be taken at a block. Jobs are keyed by their kind and
+-- height, so queueing the same snapshot twice is a no-op.
+
+--------------------------------------------------------------------------------
+
+-- name: insertSnapshotJob
+INSERT INTO snapshot_jobs ("kind", "height", "date")
+VALUES                    ($1    , $2      , $3    )
+ON CONFLICT ON CONSTRAINT snapshot_jobs_natural_key
+DO NOTHING;
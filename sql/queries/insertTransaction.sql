@@ -1,9 +1,28 @@
 -- Insert Transactions into the database, there should be one transaction in
 -- this table for EACH event type stored in others, such as burn, transfer, or
 -- escrow events.
+--
+-- Transactions are keyed by hash, so writing the same transaction twice
+-- overwrites the earlier row.
 
 --------------------------------------------------------------------------------
 
 -- name: insertTransaction
-INSERT INTO transactions ("method", "payload", "height", "date", "sender", "fee", "gas", "gas_price", "hash")
-VALUES                   ($1      , $2       , $3      , $4    , $5      , $6   , $7   , $8         , $9);
+INSERT INTO transactions ("method", "payload", "height", "date", "sender", "fee", "gas", "gas_price", "hash", "tx_index", "status", "error_module", "error_code", "error_message", "gas_used", "events")
+VALUES                   ($1      , $2       , $3      , $4    , $5      , $6   , $7   , $8         , $9    , $10       , $11     , $12           , $13         , $14            , $15       , $16)
+ON CONFLICT (hash)
+DO UPDATE   SET method        = EXCLUDED.method,
+                payload       = EXCLUDED.payload,
+                height        = EXCLUDED.height,
+                date          = EXCLUDED.date,
+                sender        = EXCLUDED.sender,
+                fee           = EXCLUDED.fee,
+                gas           = EXCLUDED.gas,
+                gas_price     = EXCLUDED.gas_price,
+                tx_index      = EXCLUDED.tx_index,
+                status        = EXCLUDED.status,
+                error_module  = EXCLUDED.error_module,
+                error_code    = EXCLUDED.error_code,
+                error_message = EXCLUDED.error_message,
+                gas_used      = EXCLUDED.gas_used,
+                events        = EXCLUDED.events;
@@ -2,11 +2,21 @@
 -- though a full transaction should be written to the transactions table that
 -- matches any event here.
 --
+-- Events are keyed by height and their index within the block, so writing the
+-- same event twice overwrites the earlier row. The extractor also clears a
+-- height with deleteBlockEvents before writing its events again.
+--
 -- TODO: Constraint such that a Transaction/Event must exist in the database at
 -- the same time.
 
 --------------------------------------------------------------------------------
 
 -- name: insertTransfer
-INSERT INTO transfers ("from", "to", "tokens", "hash", "height", "date")
-VALUES                ($1    , $2  , $3      , $4    , $5      , $6);
+INSERT INTO transfers ("from", "to", "tokens", "hash", "height", "date", "event_index")
+VALUES                ($1    , $2  , $3      , $4    , $5      , $6    , $7)
+ON CONFLICT (height, event_index)
+DO UPDATE   SET "from" = EXCLUDED."from",
+                "to"   = EXCLUDED."to",
+                tokens = EXCLUDED.tokens,
+                hash   = EXCLUDED.hash,
+                date   = EXCLUDED.date;
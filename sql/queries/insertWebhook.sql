@@ -0,0 +1,8 @@
+-- Register a webhook, returning the ID it can later be removed by.
+
+--------------------------------------------------------------------------------
+
+-- name: insertWebhook
+INSERT INTO webhooks ("url", "secret", "addresses", "kinds")
+VALUES               ($1   , $2      , $3         , $4)
+RETURNING id;
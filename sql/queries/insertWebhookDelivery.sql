@@ -0,0 +1,11 @@
+-- Queue a webhook delivery for an event. Deliveries are keyed by the event
+-- that caused them, so queueing the same one twice is a no-op and a delivery
+-- that already went out is never repeated.
+
+--------------------------------------------------------------------------------
+
+-- name: insertWebhookDelivery
+INSERT INTO webhook_deliveries ("webhook_id", "height", "event_index", "kind", "address", "payload")
+VALUES                         ($1          , $2      , $3           , $4    , $5       , $6)
+ON CONFLICT ON CONSTRAINT webhook_deliveries_natural_key
+DO NOTHING;
@@ -1,10 +1,12 @@
--- Fetch Account Snapshots for some address.
+-- Fetch Account Snapshots for some address, oldest first. Clients paging by
+-- cursor use queryAccountHistoryCursor instead, which runs newest first like
+-- every other cursor query.
 
 --------------------------------------------------------------------------------
 
 -- name: queryAccountHistory
 SELECT   * FROM account_snapshots
 WHERE    address = $1
-ORDER BY date
+ORDER BY date, height
 LIMIT    $3
 OFFSET   $2;
@@ -0,0 +1,11 @@
+-- The keyset paginated form of queryAccountHistory. There is one snapshot per
+-- account per height, so the height alone is enough to resume from.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAccountHistoryCursor
+SELECT   * FROM account_snapshots
+WHERE    address = $1
+AND      height  < $2
+ORDER BY height DESC
+LIMIT    $3;
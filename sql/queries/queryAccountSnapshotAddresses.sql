@@ -0,0 +1,9 @@
+-- List every address stored in the snapshot taken at some height.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAccountSnapshotAddresses
+SELECT   address
+FROM     account_snapshots
+WHERE    height = $1
+ORDER BY address;
@@ -0,0 +1,11 @@
+-- Fetch the most recent snapshot of an account at or below some height, this
+-- answers point-in-time reads for heights the node no longer has state for.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAccountSnapshotAt
+SELECT   * FROM account_snapshots
+WHERE    address = $1
+AND      height <= $2
+ORDER BY height DESC
+LIMIT    1;
@@ -0,0 +1,8 @@
+-- Get the number of accounts stored in the snapshot taken at some height, this
+-- is for pagination purposes.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAccountSnapshotCount
+SELECT count(*) FROM account_snapshots
+WHERE  height = $1;
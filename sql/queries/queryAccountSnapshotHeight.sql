@@ -0,0 +1,10 @@
+-- Find the most recent snapshot height at or below some height. Every account
+-- is snapshotted at the same height, so this identifies a complete snapshot.
+-- Returns NULL if no snapshot is old enough.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAccountSnapshotHeight
+SELECT MAX(height)
+FROM   account_snapshots
+WHERE  height <= $1;
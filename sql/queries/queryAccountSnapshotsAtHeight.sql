@@ -0,0 +1,10 @@
+-- Fetch a page of the accounts stored in the snapshot taken at some height.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAccountSnapshotsAtHeight
+SELECT   * FROM account_snapshots
+WHERE    height = $1
+ORDER BY address
+LIMIT    $3
+OFFSET   $2;
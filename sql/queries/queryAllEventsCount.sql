@@ -0,0 +1,9 @@
+-- Count every event returned by queryAllEvents, so list responses can report
+-- how many pages there are.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAllEventsCount
+SELECT (SELECT COUNT(*) FROM transfers)
+     + (SELECT COUNT(*) FROM escrow_changes)
+     + (SELECT COUNT(*) FROM burns);
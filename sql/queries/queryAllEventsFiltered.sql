@@ -5,36 +5,54 @@
 
 -- name: queryAllEventsFiltered
 WITH all_events AS (
-    SELECT   0                                AS height,
-             NOW()::text                      AS when,
-             'transfer'                       AS kind,
-             ( SELECT row_to_json(_)
-             FROM  (SELECT t.*) AS _)::text AS payload
+    SELECT   t.height                           AS height,
+             t.date AT TIME ZONE 'UTC'          AS "when",
+             t.hash                             AS hash,
+             t.event_index                      AS event_index,
+             'transfer'                         AS kind,
+             json_build_object(
+                 'from',   t."from",
+                 'to',     t."to",
+                 'tokens', t.tokens
+             )::text                            AS payload
     FROM     transfers t
-    UNION
+    UNION ALL
 
-    -- Condense Escrow Events
-    SELECT   0                                 AS height,
-             NOW()::text                       AS when,
-             'escrow'                          AS kind,
-             json_build_object(kind,
-             ( SELECT row_to_json(_)
-             FROM  (SELECT e.*) AS _))::text AS payload
+    -- Condense Escrow Events, keyed by their kind: add, take or reclaim.
+    SELECT   e.height                           AS height,
+             e.date AT TIME ZONE 'UTC'          AS "when",
+             e.hash                             AS hash,
+             e.event_index                      AS event_index,
+             'escrow'                           AS kind,
+             json_build_object(e.kind, json_build_object(
+                 'owner',  e.owner,
+                 'escrow', e.escrow,
+                 'tokens', e.tokens
+             ))::text                           AS payload
     FROM     escrow_changes e
-    UNION
+    UNION ALL
 
     -- Condense Burn Events
-    SELECT   0                              AS height,
-             NOW()::text                    AS when,
-             'burn'                         AS kind,
-             ( SELECT row_to_json(_)
-             FROM  (SELECT b.*) AS _)::text AS payload
+    SELECT   b.height                           AS height,
+             b.date AT TIME ZONE 'UTC'          AS "when",
+             b.hash                             AS hash,
+             b.event_index                      AS event_index,
+             'burn'                             AS kind,
+             json_build_object(
+                 'owner',  b.owner,
+                 'tokens', b.tokens
+             )::text                            AS payload
     FROM     burns b
 )
 
-SELECT   *
+SELECT   height,
+         "when",
+         hash,
+         event_index,
+         kind,
+         payload
 FROM     all_events
 WHERE    payload LIKE $1
-ORDER BY "when"
+ORDER BY height DESC, event_index DESC
 LIMIT    $3
 OFFSET   $2;
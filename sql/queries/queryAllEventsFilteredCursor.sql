@@ -0,0 +1,57 @@
+-- The keyset paginated form of queryAllEventsFiltered, see queryAllEventsCursor.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAllEventsFilteredCursor
+WITH all_events AS (
+    SELECT   t.height                           AS height,
+             t.date AT TIME ZONE 'UTC'          AS "when",
+             t.hash                             AS hash,
+             t.event_index                      AS event_index,
+             'transfer'                         AS kind,
+             json_build_object(
+                 'from',   t."from",
+                 'to',     t."to",
+                 'tokens', t.tokens
+             )::text                            AS payload
+    FROM     transfers t
+    UNION ALL
+
+    -- Condense Escrow Events, keyed by their kind: add, take or reclaim.
+    SELECT   e.height                           AS height,
+             e.date AT TIME ZONE 'UTC'          AS "when",
+             e.hash                             AS hash,
+             e.event_index                      AS event_index,
+             'escrow'                           AS kind,
+             json_build_object(e.kind, json_build_object(
+                 'owner',  e.owner,
+                 'escrow', e.escrow,
+                 'tokens', e.tokens
+             ))::text                           AS payload
+    FROM     escrow_changes e
+    UNION ALL
+
+    -- Condense Burn Events
+    SELECT   b.height                           AS height,
+             b.date AT TIME ZONE 'UTC'          AS "when",
+             b.hash                             AS hash,
+             b.event_index                      AS event_index,
+             'burn'                             AS kind,
+             json_build_object(
+                 'owner',  b.owner,
+                 'tokens', b.tokens
+             )::text                            AS payload
+    FROM     burns b
+)
+
+SELECT   height,
+         "when",
+         hash,
+         event_index,
+         kind,
+         payload
+FROM     all_events
+WHERE    payload LIKE $1
+AND      (height, event_index) < ($2, $3)
+ORDER BY height DESC, event_index DESC
+LIMIT    $4;
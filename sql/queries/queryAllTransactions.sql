@@ -1,5 +1,6 @@
--- Fetch all Transactions from the database, but filters out any query by the
--- registry module (these seem pointless to show for 99% of users).
+-- Fetch all Transactions from the database, including registry transactions.
+-- Transactions are returned newest first, in the same order as
+-- queryAllTransactionsCursor.
 
 --------------------------------------------------------------------------------
 
@@ -13,9 +14,15 @@ SELECT   id,
          height,
          method,
          payload,
-         sender
+         sender,
+         status,
+         error_module,
+         error_code,
+         error_message,
+         gas_used,
+         events,
+         tx_index
 FROM     transactions
-WHERE    method NOT LIKE '%registry%'
-ORDER BY date
+ORDER BY height DESC, tx_index DESC
 LIMIT    $2
 OFFSET   $1;
@@ -0,0 +1,7 @@
+-- Count every transaction returned by queryAllTransactions.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAllTransactionsCount
+SELECT   COUNT(*)
+FROM     transactions;
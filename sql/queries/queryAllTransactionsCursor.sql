@@ -0,0 +1,28 @@
+-- The keyset paginated form of queryAllTransactions. Transactions are returned
+-- newest first, starting before the height and index of the last transaction
+-- a client has seen.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAllTransactionsCursor
+SELECT   id,
+         date,
+         fee,
+         gas,
+         gas_price,
+         hash,
+         height,
+         method,
+         payload,
+         sender,
+         status,
+         error_module,
+         error_code,
+         error_message,
+         gas_used,
+         events,
+         tx_index
+FROM     transactions
+WHERE    (height, tx_index) < ($1, $2)
+ORDER BY height DESC, tx_index DESC
+LIMIT    $3;
@@ -1,6 +1,8 @@
 -- This query is similar to queryAllTransactions, but also allows filtering
 -- specifically for transactions that are associated with a specific oasis
--- address.
+-- address. Besides the sender and the staking payload fields, registry payloads
+-- are matched on the entity or node they register and the nodes an entity
+-- lists, so an entity finds registrations signed by its nodes' keys.
 
 --------------------------------------------------------------------------------
 
@@ -14,13 +16,23 @@ SELECT   id,
          height,
          method,
          payload,
-         sender
+         sender,
+         status,
+         error_module,
+         error_code,
+         error_message,
+         gas_used,
+         events,
+         tx_index
 FROM     transactions
 WHERE    (
-    sender           LIKE $1 OR
-    payload->>'to'   LIKE $1 OR
-    payload->>'from' LIKE $1
+    sender                LIKE $1 OR
+    payload->>'to'        LIKE $1 OR
+    payload->>'from'      LIKE $1 OR
+    payload->>'id'        LIKE $1 OR
+    payload->>'entity_id' LIKE $1 OR
+    payload->>'nodes'     LIKE $1
 )
-ORDER BY date
+ORDER BY height DESC, tx_index DESC
 LIMIT    $3
 OFFSET   $2;
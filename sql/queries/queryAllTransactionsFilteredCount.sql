@@ -0,0 +1,16 @@
+-- Count every transaction returned by queryAllTransactionsFiltered for the same
+-- filter.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAllTransactionsFilteredCount
+SELECT   COUNT(*)
+FROM     transactions
+WHERE    (
+    sender                LIKE $1 OR
+    payload->>'to'        LIKE $1 OR
+    payload->>'from'      LIKE $1 OR
+    payload->>'id'        LIKE $1 OR
+    payload->>'entity_id' LIKE $1 OR
+    payload->>'nodes'     LIKE $1
+);
@@ -0,0 +1,35 @@
+-- The keyset paginated form of queryAllTransactionsFiltered, see
+-- queryAllTransactionsCursor.
+
+--------------------------------------------------------------------------------
+
+-- name: queryAllTransactionsFilteredCursor
+SELECT   id,
+         date,
+         fee,
+         gas,
+         gas_price,
+         hash,
+         height,
+         method,
+         payload,
+         sender,
+         status,
+         error_module,
+         error_code,
+         error_message,
+         gas_used,
+         events,
+         tx_index
+FROM     transactions
+WHERE    (
+    sender                LIKE $1 OR
+    payload->>'to'        LIKE $1 OR
+    payload->>'from'      LIKE $1 OR
+    payload->>'id'        LIKE $1 OR
+    payload->>'entity_id' LIKE $1 OR
+    payload->>'nodes'     LIKE $1
+)
+AND      (height, tx_index) < ($2, $3)
+ORDER BY height DESC, tx_index DESC
+LIMIT    $4;
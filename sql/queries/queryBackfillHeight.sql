@@ -0,0 +1,10 @@
+-- Query the last height processed by the backfill command. This is tracked
+-- separately from the sync height so that re-indexing old ranges never moves
+-- the extractor's own position at the tip.
+
+--------------------------------------------------------------------------------
+
+-- name: queryBackfillHeight
+SELECT value::int8
+FROM   chain_sync_state
+WHERE  kind = 'backfill_height';
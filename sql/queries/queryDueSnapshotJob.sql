@@ -0,0 +1,18 @@
+-- Fetch the oldest pending snapshot job of some kind whose next attempt is due,
+-- locking it for the rest of the transaction. Jobs locked by another process
+-- are skipped, so two processes never take the same job.
+
+--------------------------------------------------------------------------------
+
+-- name: queryDueSnapshotJob
+SELECT   id,
+         height,
+         date,
+         attempts
+FROM     snapshot_jobs
+WHERE    kind = $1
+AND      status = 'pending'
+AND      next_attempt <= NOW()
+ORDER BY height
+LIMIT    1
+FOR UPDATE SKIP LOCKED;
@@ -0,0 +1,30 @@
+-- Fetch pending webhook deliveries whose next attempt is due, oldest first,
+-- along with where to send them and the secret to sign them with. At most $2
+-- deliveries are taken per webhook, so a webhook with a large backlog cannot
+-- fill a whole batch on its own.
+
+--------------------------------------------------------------------------------
+
+-- name: queryDueWebhookDeliveries
+SELECT   id,
+         webhook_id,
+         payload,
+         attempts,
+         url,
+         secret
+FROM     (
+    SELECT d.id,
+           d.webhook_id,
+           d.payload,
+           d.attempts,
+           w.url,
+           w.secret,
+           row_number() OVER (PARTITION BY d.webhook_id ORDER BY d.id) AS position
+    FROM   webhook_deliveries d
+    JOIN   webhooks w ON w.id = d.webhook_id
+    WHERE  d.status = 'pending'
+    AND    d.next_attempt <= NOW()
+) AS due
+WHERE    position <= $2
+ORDER BY id
+LIMIT    $1;
@@ -0,0 +1,15 @@
+-- Find the most recent Genesis Snapshot stored in full, along with how many
+-- snapshots have been stored as diffs against it. New snapshots are diffed
+-- against this one until enough diffs have accumulated.
+
+--------------------------------------------------------------------------------
+
+-- name: queryGenesisBase
+SELECT   base.height,
+         (SELECT COUNT(*)
+          FROM   genesis_snapshots diff
+          WHERE  diff.base_height = base.height)
+FROM     genesis_snapshots base
+WHERE    base.base_height IS NULL
+ORDER BY base.height DESC
+LIMIT    1;
@@ -0,0 +1,20 @@
+-- Fetch the most recent Genesis Snapshot at or below some height. When it was
+-- stored as a diff, the height of the full snapshot it applies to is returned
+-- along with that snapshot's data and its own base_height, which must be NULL
+-- for the diff to be valid. The base columns are NULL for full snapshots, and
+-- the base data is NULL if the base is missing.
+
+--------------------------------------------------------------------------------
+
+-- name: queryGenesisSnapshotAt
+SELECT    snapshot.height,
+          snapshot.date,
+          snapshot.snapshot_data,
+          snapshot.base_height,
+          base.snapshot_data,
+          base.base_height
+FROM      genesis_snapshots snapshot
+LEFT JOIN genesis_snapshots base ON base.height = snapshot.base_height
+WHERE     snapshot.height <= $1
+ORDER BY  snapshot.height DESC
+LIMIT     1;
@@ -0,0 +1,9 @@
+-- Fetch the stored data of the Genesis Snapshot at some height, as stored: a
+-- full dump, or a diff when its base_height is set.
+
+--------------------------------------------------------------------------------
+
+-- name: queryGenesisSnapshotData
+SELECT snapshot_data
+FROM   genesis_snapshots
+WHERE  height = $1;
@@ -0,0 +1,10 @@
+-- Check whether a Genesis Snapshot has already been stored at some height.
+
+--------------------------------------------------------------------------------
+
+-- name: queryGenesisSnapshotExists
+SELECT EXISTS (
+    SELECT 1
+    FROM   genesis_snapshots
+    WHERE  height = $1
+);
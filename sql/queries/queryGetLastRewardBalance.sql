@@ -1,10 +1,11 @@
--- This fetches the last event that added escrow to an account, by scanning for
--- the special address that identifies the common pool.
+-- This fetches the total escrow an account has been rewarded with, by scanning
+-- for the special address that identifies the common pool. Accounts that have
+-- never been rewarded get zero, so every account is still snapshotted.
 
 --------------------------------------------------------------------------------
 
 -- name: queryGetLastRewardBalance
-SELECT SUM(tokens::int8)
+SELECT COALESCE(SUM(tokens::int8), 0)
 FROM   escrow_changes
 WHERE  owner   = 'oasis1qrmufhkkyyf79s5za2r8yga9gnk4t446dcy3a5zm' AND
        escrow  = $1 AND
@@ -12,7 +12,14 @@ SELECT   id,
          height,
          method,
          payload,
-         sender
+         sender,
+         status,
+         error_module,
+         error_code,
+         error_message,
+         gas_used,
+         events,
+         tx_index
 FROM     transactions
 WHERE    hash = $1
 LIMIT    1;
@@ -0,0 +1,20 @@
+-- Read the delivery log of a webhook, newest first.
+
+--------------------------------------------------------------------------------
+
+-- name: queryWebhookDeliveries
+SELECT   id,
+         height,
+         event_index,
+         kind,
+         address,
+         status,
+         attempts,
+         response_code,
+         last_error,
+         created,
+         delivered
+FROM     webhook_deliveries
+WHERE    webhook_id = $1
+ORDER BY id DESC
+LIMIT    $2;
@@ -0,0 +1,14 @@
+-- List every registered webhook. The extractor matches each block's events
+-- against this list, which is expected to stay small.
+
+--------------------------------------------------------------------------------
+
+-- name: queryWebhooks
+SELECT   id,
+         url,
+         secret,
+         addresses,
+         kinds,
+         created
+FROM     webhooks
+ORDER BY id;
@@ -0,0 +1,11 @@
+-- Update the last height processed by the backfill command. This should be run
+-- every time a block is backfilled so that an interrupted backfill can resume
+-- where it stopped.
+
+--------------------------------------------------------------------------------
+
+-- name: updateBackfillHeight
+INSERT INTO chain_sync_state (kind              , value)
+VALUES                       ('backfill_height' , $1)
+ON CONFLICT (kind)
+DO UPDATE   SET value = $1;
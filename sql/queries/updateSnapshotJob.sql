@@ -0,0 +1,12 @@
+-- Record the outcome of an attempt to take a snapshot job.
+
+--------------------------------------------------------------------------------
+
+-- name: updateSnapshotJob
+UPDATE snapshot_jobs
+SET    status       = $2,
+       attempts     = $3,
+       next_attempt = $4,
+       last_error   = $5,
+       finished     = $6
+WHERE  id = $1;
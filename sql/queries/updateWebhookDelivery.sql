@@ -0,0 +1,13 @@
+-- Record the outcome of a webhook delivery attempt.
+
+--------------------------------------------------------------------------------
+
+-- name: updateWebhookDelivery
+UPDATE webhook_deliveries
+SET    status        = $2,
+       attempts      = $3,
+       next_attempt  = $4,
+       response_code = $5,
+       last_error    = $6,
+       delivered     = $7
+WHERE  id = $1;